@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/illikainen/go-utils/src/sandbox"
 
@@ -24,9 +27,17 @@ func main() {
 
 	ensure.Unprivileged()
 
-	err := cmd.Command().Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c, err := cmd.Command().ExecuteContextC(ctx)
 	if err != nil {
 		log.Tracef("%+v", err)
-		log.Fatalf("%s", err)
+		if ctx.Err() != nil {
+			log.Errorf("%s", err)
+			stop()
+			os.Exit(cmd.ExitInterrupted) // revive:disable-line
+		}
+		cmd.Fatal(c, err)
 	}
 }
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/pkg/errors"
+)
+
+// maxMessageSize limits the size of a request or a response.
+const maxMessageSize = 1024 * 1024
+
+// Client is a private key that is held by an agent.  It implements
+// cryptor.PrivateKey so that it can be used in place of a key that is read
+// from a file.
+type Client struct {
+	conn        net.Conn
+	scanner     *bufio.Scanner
+	encoder     *json.Encoder
+	fingerprint string
+	name        string
+	mu          sync.Mutex
+}
+
+// Dial connects to the agent listening on path.
+func Dial(path string) (*Client, error) {
+	// The agent may have been replaced by another user if either the
+	// socket or its directory isn't ours.
+	err := checkOwner(filepath.Dir(path), os.ModeDir, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkOwner(path, os.ModeSocket, false)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxMessageSize)
+
+	c := &Client{
+		conn:    conn,
+		scanner: scanner,
+		encoder: json.NewEncoder(conn),
+	}
+
+	res, err := c.call(&request{Op: opIdentity})
+	if err != nil {
+		return nil, errorx.Join(err, conn.Close())
+	}
+	c.fingerprint = res.Fingerprint
+	c.name = res.Name
+
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) Sign(message []byte) ([]byte, error) {
+	res, err := c.call(&request{Op: opSign, Message: message})
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+func (c *Client) Decrypt(ciphertext string) ([]byte, error) {
+	res, err := c.call(&request{Op: opDecrypt, Ciphertext: ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+func (c *Client) UnmarshalJSON([]byte) error {
+	return cryptor.ErrNotImplemented
+}
+
+func (c *Client) Write(string) error {
+	return errors.Errorf("keys held by the agent can't be written")
+}
+
+func (c *Client) Fingerprint() string {
+	return c.fingerprint
+}
+
+func (c *Client) String() string {
+	return c.name
+}
+
+func (c *Client) call(req *request) (*response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.encoder.Encode(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.scanner.Scan() {
+		err := c.scanner.Err()
+		if err == nil {
+			err = errors.Errorf("the agent closed the connection")
+		}
+		return nil, err
+	}
+
+	res := &response{}
+	err = json.Unmarshal(c.scanner.Bytes(), res)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Error != "" {
+		return nil, errors.Errorf("agent: %s", res.Error)
+	}
+
+	return res, nil
+}
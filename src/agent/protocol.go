@@ -0,0 +1,27 @@
+package agent
+
+// The agent speaks newline-delimited JSON over a unix socket.  Each
+// request is answered by exactly one response, and a connection may be
+// used for any number of requests.
+
+// SockEnv is the environment variable with the path of the agent socket.
+const SockEnv = "BAMBI_AGENT_SOCK"
+
+const (
+	opIdentity = "identity"
+	opSign     = "sign"
+	opDecrypt  = "decrypt"
+)
+
+type request struct {
+	Op         string
+	Message    []byte `json:",omitempty"`
+	Ciphertext string `json:",omitempty"`
+}
+
+type response struct {
+	Error       string `json:",omitempty"`
+	Fingerprint string `json:",omitempty"`
+	Name        string `json:",omitempty"`
+	Data        []byte `json:",omitempty"`
+}
@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type Server struct {
+	key      cryptor.PrivateKey
+	listener net.Listener
+	path     string
+	mu       sync.Mutex
+}
+
+// Listen creates a server that holds key and listens on a unix socket at
+// path.  The socket is only accessible by the current user, and its
+// directory must only be writable by the current user.
+func Listen(path string, key cryptor.PrivateKey) (*Server, error) {
+	if key == nil {
+		return nil, errors.Errorf("a private key must be configured for the agent")
+	}
+
+	err := checkOwner(filepath.Dir(path), os.ModeDir, false)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := listen(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		key:      key,
+		listener: listener,
+		path:     path,
+	}, nil
+}
+
+// MkdirPrivate creates dir if it doesn't exist and checks that it's owned
+// by the current user with mode 0700.  It's used for directories in shared
+// locations such as /tmp that another user could have created first.
+func MkdirPrivate(dir string) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	return checkOwner(dir, os.ModeDir, true)
+}
+
+// Serve answers requests until ctx is done or until timeout has passed.
+// The key is dropped and the socket is removed when Serve returns.  The
+// server doesn't time out if timeout is 0.
+func (s *Server) Serve(ctx context.Context, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	go func() {
+		<-ctx.Done()
+		err := s.listener.Close()
+		if err != nil {
+			log.Debugf("%s: %s", s.path, err)
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.drop()
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		err := conn.Close()
+		if err != nil {
+			log.Tracef("%s: %s", s.path, err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxMessageSize)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		req := &request{}
+		res := &response{}
+
+		err := json.Unmarshal(scanner.Bytes(), req)
+		if err == nil {
+			res, err = s.answer(req)
+		}
+		if err != nil {
+			res = &response{Error: err.Error()}
+		}
+
+		err = encoder.Encode(res)
+		if err != nil {
+			log.Debugf("%s: %s", s.path, err)
+			return
+		}
+	}
+}
+
+func (s *Server) answer(req *request) (*response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.key == nil {
+		return nil, errors.Errorf("the agent doesn't hold a key")
+	}
+
+	switch req.Op {
+	case opIdentity:
+		return &response{
+			Fingerprint: s.key.Fingerprint(),
+			Name:        s.key.String(),
+		}, nil
+	case opSign:
+		log.Infof("signing for a client")
+		sig, err := s.key.Sign(req.Message)
+		if err != nil {
+			return nil, err
+		}
+		return &response{Data: sig}, nil
+	case opDecrypt:
+		log.Infof("decrypting for a client")
+		plaintext, err := s.key.Decrypt(req.Ciphertext)
+		if err != nil {
+			return nil, err
+		}
+		return &response{Data: plaintext}, nil
+	default:
+		return nil, errors.Errorf("invalid request: %s", req.Op)
+	}
+}
+
+func (s *Server) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = nil
+	log.Infof("dropped the private key")
+}
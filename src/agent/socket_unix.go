@@ -0,0 +1,55 @@
+//go:build unix
+
+package agent
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// listen creates the socket with a umask so that it's never accessible by
+// other users, not even before it could be chmodded.
+func listen(path string) (net.Listener, error) {
+	umask := syscall.Umask(0177)
+	defer syscall.Umask(umask)
+
+	return net.Listen("unix", path)
+}
+
+// checkOwner returns an error unless path is a file of type typ that is
+// owned by the current user and only writable by the current user, or
+// only accessible by the current user if private is set.  Sockets and
+// their directories are checked so that another user can't replace the
+// socket with their own.
+func checkOwner(path string, typ os.FileMode, private bool) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Type() != typ {
+		return errors.Errorf("%s: unexpected file type (%s)", path, info.Mode().Type())
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.Errorf("%s: unsupported file info", path)
+	}
+
+	if int(stat.Uid) != os.Getuid() {
+		return errors.Errorf("%s: not owned by the current user", path)
+	}
+
+	mask := os.FileMode(0022)
+	if private {
+		mask = 0077
+	}
+	if info.Mode().Perm()&mask != 0 {
+		return errors.Errorf("%s: accessible by other users (%s)", path, info.Mode().Perm())
+	}
+
+	return nil
+}
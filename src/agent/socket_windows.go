@@ -0,0 +1,30 @@
+//go:build windows
+
+package agent
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// listen creates the socket.  Access is controlled by the ACL of the
+// directory on Windows.
+func listen(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
+
+// checkOwner only checks the type of path because ownership is expressed
+// by ACLs on Windows.
+func checkOwner(path string, typ os.FileMode, _ bool) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Type() != typ {
+		return errors.Errorf("%s: unexpected file type (%s)", path, info.Mode().Type())
+	}
+	return nil
+}
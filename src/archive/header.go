@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The header is stored as vendor-specific PAX records in a global header at
+// the start of the archive.  The archive is part of the signed payload of
+// the blob, so the header is covered by the signature.
+const (
+	labelPrefix = "BAMBI.label."
+	expiresKey  = "BAMBI.expires"
+)
+
+type Header struct {
+	Labels map[string]string
+
+	// Expires is the Unix time after which the archive shouldn't be
+	// trusted, or 0 if it doesn't expire.
+	Expires int64
+}
+
+func (h *Header) records() (map[string]string, error) {
+	records := map[string]string{}
+	for key, value := range h.Labels {
+		err := validateLabel(key, value)
+		if err != nil {
+			return nil, err
+		}
+		records[labelPrefix+key] = value
+	}
+
+	if h.Expires < 0 {
+		return nil, errors.Errorf("invalid expiry time: %d", h.Expires)
+	}
+	if h.Expires > 0 {
+		records[expiresKey] = strconv.FormatInt(h.Expires, 10)
+	}
+
+	return records, nil
+}
+
+func parseHeader(records map[string]string) (*Header, error) {
+	hdr := &Header{Labels: map[string]string{}}
+
+	for key, value := range records {
+		switch {
+		case strings.HasPrefix(key, labelPrefix):
+			name := strings.TrimPrefix(key, labelPrefix)
+			err := validateLabel(name, value)
+			if err != nil {
+				return nil, err
+			}
+			hdr.Labels[name] = value
+		case key == expiresKey:
+			expires, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || expires <= 0 {
+				return nil, errors.Errorf("invalid expiry time: %s", value)
+			}
+			hdr.Expires = expires
+		}
+	}
+
+	return hdr, nil
+}
@@ -0,0 +1,38 @@
+package archive
+
+import (
+	"strings"
+
+	"github.com/illikainen/go-utils/src/stringx"
+	"github.com/pkg/errors"
+)
+
+func ParseLabel(s string) (string, string, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", errors.Errorf("%s: labels must be specified as key=value", s)
+	}
+
+	err := validateLabel(key, value)
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, value, nil
+}
+
+func validateLabel(key string, value string) error {
+	if key == "" {
+		return errors.Errorf("empty label key")
+	}
+
+	if strings.ContainsAny(key, "=\n") {
+		return errors.Errorf("%s: invalid label key", key)
+	}
+
+	if stringx.Sanitize(key) != key || stringx.Sanitize(value) != value {
+		return errors.Errorf("%s: invalid label characters", key)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package archive
 
 import (
 	"archive/tar"
+	"context"
 	"io"
 	"io/fs"
 	"os"
@@ -18,6 +19,11 @@ import (
 type ArchiveReader struct {
 	reader io.ReadSeeker
 	tar    *tar.Reader
+	dirty  bool
+
+	scanned bool
+	header  *Header
+	entries []Entry
 }
 
 func NewReader(r io.ReadSeeker) (*ArchiveReader, error) {
@@ -31,8 +37,12 @@ func (r *ArchiveReader) Close() error {
 	return nil
 }
 
+// ExtractAll extracts every entry into basedir.  Entries that have been
+// extracted are removed if an error occurs, or if ctx is cancelled, before
+// the archive has been extracted in full.
+//
 // revive:disable-next-line
-func (r *ArchiveReader) ExtractAll(basedir string) (err error) {
+func (r *ArchiveReader) ExtractAll(ctx context.Context, basedir string) (err error) {
 	basedir = filepath.Clean(basedir)
 
 	entries, err := r.List()
@@ -72,8 +82,20 @@ func (r *ArchiveReader) ExtractAll(basedir string) (err error) {
 		}
 	}
 
+	created := []string{}
+	defer func() {
+		if err != nil {
+			err = errorx.Join(err, r.removeAll(created))
+		}
+	}()
+
 	for {
-		hdr, err := r.tar.Next()
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		hdr, err := r.next()
 		if err != nil {
 			if err == io.EOF {
 				break
@@ -81,6 +103,10 @@ func (r *ArchiveReader) ExtractAll(basedir string) (err error) {
 			return err
 		}
 
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
 		dst, err := r.getExtractPath(basedir, hdr.Name)
 		if err != nil {
 			return err
@@ -97,10 +123,12 @@ func (r *ArchiveReader) ExtractAll(basedir string) (err error) {
 			if err != nil {
 				return err
 			}
+			created = append(created, dst)
 		} else if hdr.Typeflag == tar.TypeReg {
 			log.Infof("extracting '%s' (regular)", dst)
 
-			err := os.MkdirAll(filepath.Dir(dst), 0700)
+			dirs, err := r.mkdirAll(filepath.Dir(dst))
+			created = append(created, dirs...)
 			if err != nil {
 				return err
 			}
@@ -115,8 +143,9 @@ func (r *ArchiveReader) ExtractAll(basedir string) (err error) {
 			if err != nil {
 				return err
 			}
+			created = append(created, dst)
 
-			err = iofs.Copy(f, r.tar)
+			err = iofs.Copy(f, &contextReader{ctx: ctx, reader: r.tar})
 			if err != nil {
 				return errorx.Join(err, f.Close())
 			}
@@ -128,7 +157,8 @@ func (r *ArchiveReader) ExtractAll(basedir string) (err error) {
 		} else if hdr.Typeflag == tar.TypeDir {
 			log.Infof("extracting '%s' (dir)", dst)
 
-			err := os.MkdirAll(dst, 0700)
+			dirs, err := r.mkdirAll(dst)
+			created = append(created, dirs...)
 			if err != nil {
 				return err
 			}
@@ -140,6 +170,44 @@ func (r *ArchiveReader) ExtractAll(basedir string) (err error) {
 	return nil
 }
 
+// mkdirAll is like os.MkdirAll() but it returns the directories that were
+// created, starting with the outermost one.
+func (r *ArchiveReader) mkdirAll(path string) ([]string, error) {
+	missing := []string{}
+	for dir := path; ; dir = filepath.Dir(dir) {
+		exists, err := iofs.Exists(dir)
+		if err != nil {
+			return nil, err
+		}
+		if exists || dir == filepath.Dir(dir) {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+	}
+
+	created := []string{}
+	for _, dir := range missing {
+		err := os.Mkdir(dir, 0700)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, dir)
+	}
+
+	return created, nil
+}
+
+// removeAll removes paths in reverse order so that the content of a
+// directory is removed before the directory itself.
+func (r *ArchiveReader) removeAll(paths []string) error {
+	var errs []error
+	for i := len(paths) - 1; i >= 0; i-- {
+		log.Infof("removing '%s'", paths[i])
+		errs = append(errs, os.Remove(paths[i]))
+	}
+	return errorx.Join(errs...)
+}
+
 func (r *ArchiveReader) getExtractPath(basedir string, name string) (string, error) {
 	cleanName := filepath.Clean(name)
 	if filepath.IsAbs(cleanName) {
@@ -211,35 +279,102 @@ type Entry struct {
 	Path     string
 	LinkPath string
 	Mode     string
+	Size     int64
 }
 
+// List returns the entries in the archive.
 func (r *ArchiveReader) List() ([]Entry, error) {
-	entries := []Entry{}
+	err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	return r.entries, nil
+}
 
+// Header returns the global header of the archive.  An empty header is
+// returned if the archive doesn't have one.
+func (r *ArchiveReader) Header() (*Header, error) {
+	err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	return r.header, nil
+}
+
+// scan reads the global header and the entries of the archive in a single
+// pass and caches them, because every pass over a blob decrypts all of it.
+func (r *ArchiveReader) scan() error {
+	if r.scanned {
+		return nil
+	}
+
+	err := r.reset()
+	if err != nil {
+		return err
+	}
+
+	var records map[string]string
+	entries := []Entry{}
 	for {
-		hdr, err := r.tar.Next()
+		hdr, err := r.next()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return nil, err
+			return err
 		}
+
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			if len(entries) == 0 && records == nil {
+				records = hdr.PAXRecords
+			}
+			continue
+		}
+
 		entries = append(entries, Entry{
 			Path:     hdr.Name,
 			LinkPath: hdr.Linkname,
 			Mode:     hdr.FileInfo().Mode().String(),
+			Size:     hdr.Size,
 		})
 	}
 
-	err := r.reset()
+	header, err := parseHeader(records)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	err = r.reset()
+	if err != nil {
+		return err
 	}
 
-	return entries, nil
+	r.header = header
+	r.entries = entries
+	r.scanned = true
+	return nil
+}
+
+func (r *ArchiveReader) next() (*tar.Header, error) {
+	r.dirty = true
+	return r.tar.Next()
 }
 
+// reset rewinds the archive if anything has been read from it.
 func (r *ArchiveReader) reset() error {
+	if !r.dirty {
+		return nil
+	}
+
+	// Readers such as blob.Reader keep a buffer of decrypted data that
+	// isn't discarded by Seek(), so the rest of the reader is drained
+	// before seeking back to the start.  It's only the padding at the end
+	// of the archive once every entry has been read.
+	_, err := io.Copy(io.Discard, r.reader)
+	if err != nil {
+		return err
+	}
+
 	pos, err := r.reader.Seek(0, io.SeekStart)
 	if err != nil {
 		return err
@@ -248,5 +383,6 @@ func (r *ArchiveReader) reset() error {
 		return errors.Errorf("bug")
 	}
 	r.tar = tar.NewReader(r.reader)
+	r.dirty = false
 	return nil
 }
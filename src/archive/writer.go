@@ -2,6 +2,7 @@ package archive
 
 import (
 	"archive/tar"
+	"context"
 	"io"
 	"io/fs"
 	"os"
@@ -28,7 +29,25 @@ func (w *ArchiveWriter) Close() error {
 	return w.tar.Close()
 }
 
-func (w *ArchiveWriter) addFile(path string, info fs.FileInfo) (err error) {
+// SetHeader writes hdr as the global header of the archive.  It must be
+// called before any file is added.
+func (w *ArchiveWriter) SetHeader(hdr *Header) error {
+	records, err := hdr.records()
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	return w.tar.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: records,
+	})
+}
+
+func (w *ArchiveWriter) addFile(ctx context.Context, path string, info fs.FileInfo) (err error) {
 	link := ""
 	mode := info.Mode()
 
@@ -68,16 +87,19 @@ func (w *ArchiveWriter) addFile(path string, info fs.FileInfo) (err error) {
 			return err
 		}
 		defer errorx.Defer(f.Close, &err)
-		return iofs.Copy(w.tar, f)
+		return iofs.Copy(w.tar, &contextReader{ctx: ctx, reader: f})
 	}
 	return nil
 }
 
-func (w *ArchiveWriter) AddAll(paths ...string) error {
+func (w *ArchiveWriter) AddAll(ctx context.Context, paths ...string) error {
 	for _, path := range paths {
 		err := filepath.Walk(path, func(path string, info fs.FileInfo, err error) error {
 			if err == nil {
-				return w.addFile(path, info)
+				err = ctx.Err()
+			}
+			if err == nil {
+				return w.addFile(ctx, path, info)
 			}
 			return err
 		})
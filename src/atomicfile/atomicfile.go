@@ -0,0 +1,158 @@
+package atomicfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// File is a temporary file in the same directory as its target.  The target
+// is replaced by the temporary file when Commit() is invoked.  The target is
+// left untouched if the file is closed without being committed.
+//
+// An advisory lock is held on the target from Create() until Close() so that
+// concurrent writers of the same target are serialized.  The lock file is
+// removed when the lock is released.
+type File struct {
+	*os.File
+	path      string
+	lock      *os.File
+	committed bool
+	closed    bool
+}
+
+var ErrCommitted = errors.New("the file has already been committed")
+
+func Create(path string) (*File, error) {
+	lockPath := LockPath(path)
+	lock, err := acquire(lockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The partial file is only accessed while the lock is held, so an
+	// existing partial file is a leftover from an interrupted run.
+	partial := PartialPath(path)
+	err = iofs.Remove(partial)
+	if err != nil {
+		return nil, errorx.Join(err, release(lockPath, lock))
+	}
+
+	f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600) // #nosec G304
+	if err != nil {
+		return nil, errorx.Join(err, release(lockPath, lock))
+	}
+	log.Tracef("%s: writing to %s", path, partial)
+
+	return &File{
+		File: f,
+		path: path,
+		lock: lock,
+	}, nil
+}
+
+// CopyExisting copies the current content of the target, if any, to the
+// temporary file.  It's used for blobs that are updated in-place.
+func (f *File) CopyExisting() (err error) {
+	exists, err := iofs.Exists(f.path)
+	if err != nil || !exists {
+		return err
+	}
+
+	src, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(src.Close, &err)
+
+	err = iofs.Copy(f.File, src)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// Commit syncs the temporary file and renames it to the target.
+func (f *File) Commit() error {
+	if f.committed {
+		return ErrCommitted
+	}
+
+	err := f.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = f.File.Close()
+	if err != nil {
+		return err
+	}
+	f.closed = true
+
+	err = os.Rename(f.Name(), f.path)
+	if err != nil {
+		return err
+	}
+	f.committed = true
+	log.Tracef("%s: renamed from %s", f.path, f.Name())
+
+	return syncDir(filepath.Dir(f.path))
+}
+
+// Close removes the temporary file unless it has been committed and
+// releases the lock on the target.
+func (f *File) Close() error {
+	var errs []error
+	if !f.closed {
+		errs = append(errs, f.File.Close())
+		f.closed = true
+	}
+
+	if !f.committed {
+		errs = append(errs, iofs.Remove(f.Name()))
+	}
+
+	if f.lock != nil {
+		errs = append(errs, release(LockPath(f.path), f.lock))
+		f.lock = nil
+	}
+
+	return errorx.Join(errs...)
+}
+
+// isCurrent returns true if f is the file at path.
+func isCurrent(path string, f *os.File) (bool, error) {
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return os.SameFile(fileInfo, pathInfo), nil
+}
+
+// PartialPath returns the path of the temporary file for path.
+func PartialPath(path string) string {
+	dir, base := filepath.Split(path)
+	return filepath.Join(dir, "."+base+".partial")
+}
+
+// LockPath returns the path of the lock file for path.
+func LockPath(path string) string {
+	dir, base := filepath.Split(path)
+	return filepath.Join(dir, "."+base+".lock")
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package atomicfile
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/pkg/errors"
+)
+
+func acquire(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600) // #nosec G304
+		if err != nil {
+			return nil, err
+		}
+
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err != nil {
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				err = errors.Errorf("%s is locked by another process", path)
+			}
+			return nil, errorx.Join(err, f.Close())
+		}
+
+		// The lock file is removed by release() while it's locked, so the
+		// file that was opened may have been removed before it was locked.
+		current, err := isCurrent(path, f)
+		if err != nil {
+			return nil, errorx.Join(err, f.Close())
+		}
+		if current {
+			return f, nil
+		}
+
+		err = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// release removes the lock file before it's unlocked so that it's never
+// removed while another process holds the lock.
+func release(path string, f *os.File) error {
+	err := os.Remove(path)
+	err = errorx.Join(err, syscall.Flock(int(f.Fd()), syscall.LOCK_UN))
+	return errorx.Join(err, f.Close())
+}
+
+func syncDir(path string) (err error) {
+	dir, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(dir.Close, &err)
+
+	return dir.Sync()
+}
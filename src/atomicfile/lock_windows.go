@@ -0,0 +1,60 @@
+//go:build windows
+
+package atomicfile
+
+import (
+	"os"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+func acquire(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600) // #nosec G304
+		if err != nil {
+			return nil, err
+		}
+
+		flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+		err = windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &windows.Overlapped{})
+		if err != nil {
+			if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+				err = errors.Errorf("%s is locked by another process", path)
+			}
+			return nil, errorx.Join(err, f.Close())
+		}
+
+		// The lock file is removed by release(), so the file that was
+		// opened may have been removed before it was locked.
+		current, err := isCurrent(path, f)
+		if err != nil {
+			return nil, errorx.Join(err, f.Close())
+		}
+		if current {
+			return f, nil
+		}
+
+		err = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// release removes the lock file after it's closed because open files
+// can't be removed on Windows.  The removal fails if another process has
+// opened the file in the meantime, in which case it's left for that
+// process.
+func release(path string, f *os.File) error {
+	err := windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+	err = errorx.Join(err, f.Close())
+	_ = os.Remove(path)
+	return err
+}
+
+// Directories can't be synced on Windows.
+func syncDir(_ string) error {
+	return nil
+}
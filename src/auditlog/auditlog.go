@@ -0,0 +1,355 @@
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/illikainen/bambi/src/atomicfile"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+)
+
+// Entry records a command that sealed or verified a blob.  The audit log
+// is a file with one JSON entry per line.  Each entry includes the hash of
+// the previous entry, so an entry can't be edited or removed without
+// breaking the chain of every later entry.  Entries at the end of the log
+// can be removed without breaking the chain, so the number of entries and
+// the hash of the last entry are also kept in a head file next to the log.
+//
+// The hashes and the head are HMAC-SHA256 with a secret key, so the log
+// can't be rebuilt or truncated without the key.  The key must be kept
+// outside the directory of the log, but anyone who can read it can still
+// forge the log.  Nothing stops the log from being rolled back to an
+// earlier head that someone kept a copy of.
+type Entry struct {
+	Seq     int
+	Time    int64
+	Command string
+	Path    string            `json:",omitempty"`
+	URL     string            `json:",omitempty"`
+	Signer  string            `json:",omitempty"`
+	Hashes  map[string]string `json:",omitempty"`
+	Error   string            `json:",omitempty"`
+	Prev    string
+	Hash    string
+}
+
+type Head struct {
+	Count int
+	Hash  string
+	MAC   string
+}
+
+// KeySize is the size of the key for the log.
+const KeySize = 32
+
+// Log is a verified audit log.
+type Log struct {
+	Entries []*Entry
+	Head    *Head
+}
+
+// HeadPath returns the path of the head file for the log in path.
+func HeadPath(path string) string {
+	return path + ".head"
+}
+
+// CreateKey writes a random key for a log to path unless it already
+// exists.
+func CreateKey(path string) (err error) {
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600) // #nosec G304
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(f.Close, &err)
+
+	key := make([]byte, KeySize)
+	_, err = rand.Read(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(key)
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// ReadKey reads a key that was written by CreateKey().
+func ReadKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != KeySize {
+		return nil, errors.Errorf("%s: invalid key size: %d", path, len(key))
+	}
+	return key, nil
+}
+
+// Append adds entry to the log in path.  The log is verified first so that
+// an entry is never chained to a log that has been tampered with.
+func Append(path string, key []byte, entry *Entry) (err error) {
+	// The lock on the head serializes concurrent writers of the log.
+	head, err := atomicfile.Create(HeadPath(path))
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(head.Close, &err)
+
+	l, err := Verify(path, key)
+	if err != nil {
+		return err
+	}
+
+	entry.Seq = len(l.Entries) + 1
+	entry.Time = time.Now().Unix()
+	entry.Prev = ""
+	if len(l.Entries) > 0 {
+		entry.Prev = l.Entries[len(l.Entries)-1].Hash
+	}
+	entry.Hash, err = entry.digest(key)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	err = appendLine(path, line)
+	if err != nil {
+		return err
+	}
+
+	h := &Head{Count: entry.Seq, Hash: entry.Hash}
+	h.MAC, err = h.digest(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = head.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return head.Commit()
+}
+
+// Verify checks the chain of entries in the log in path and that the log
+// matches its head.  Entries after the head are accepted because they're
+// left if the head can't be written after an entry is appended.  A log
+// without a head or entries is empty.
+func Verify(path string, key []byte) (*Log, error) {
+	headExists, err := iofs.Exists(HeadPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	logExists, err := iofs.Exists(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !headExists && !logExists {
+		return &Log{Entries: []*Entry{}, Head: &Head{}}, nil
+	}
+
+	if !headExists {
+		return nil, errors.Errorf("%s: missing head", path)
+	}
+
+	head, err := readHead(HeadPath(path), key)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*Entry{}
+	if logExists {
+		entries, err = readEntries(path, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(entries) < head.Count {
+		return nil, errors.Errorf("%s: the log is truncated (%d of %d entries)", path, len(entries),
+			head.Count)
+	}
+
+	if head.Count > 0 && entries[head.Count-1].Hash != head.Hash {
+		return nil, errors.Errorf("%s: entry %d doesn't match the head", path, head.Count)
+	}
+
+	return &Log{Entries: entries, Head: head}, nil
+}
+
+func readHead(path string, key []byte) (*Head, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+
+	head := &Head{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(head)
+	if err != nil {
+		return nil, errors.Errorf("%s: invalid head: %s", path, err)
+	}
+
+	if head.Count < 1 {
+		return nil, errors.Errorf("%s: invalid head: %d entries", path, head.Count)
+	}
+
+	mac, err := head.digest(key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(mac), []byte(head.MAC)) {
+		return nil, errors.Errorf("%s: invalid head: the MAC doesn't match", path)
+	}
+
+	return head, nil
+}
+
+func readEntries(path string, key []byte) ([]*Entry, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return nil, errors.Errorf("%s: the last entry is incomplete", path)
+	}
+
+	entries := []*Entry{}
+	prev := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		entry, err := parseEntry(scanner.Bytes(), key)
+		if err != nil {
+			return nil, errors.Errorf("%s: entry %d: %s", path, len(entries)+1, err)
+		}
+
+		if entry.Seq != len(entries)+1 {
+			return nil, errors.Errorf("%s: entry %d: invalid sequence number: %d", path, len(entries)+1,
+				entry.Seq)
+		}
+
+		if entry.Prev != prev {
+			return nil, errors.Errorf("%s: entry %d: the chain is broken", path, entry.Seq)
+		}
+
+		prev = entry.Hash
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// parseEntry returns the entry in line if its hash is valid.  The line
+// must be identical to the encoding of the entry so that no part of it
+// can be changed without changing the hash.
+func parseEntry(line []byte, key []byte) (*Entry, error) {
+	entry := &Entry{}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(encoded, line) {
+		return nil, errors.Errorf("the entry isn't canonically encoded")
+	}
+
+	digest, err := entry.digest(key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(digest), []byte(entry.Hash)) {
+		return nil, errors.Errorf("invalid hash")
+	}
+
+	return entry, nil
+}
+
+// digest returns the hash of the entry without its own hash.
+func (e *Entry) digest(key []byte) (string, error) {
+	entry := *e
+	entry.Hash = ""
+	return mac(key, &entry)
+}
+
+// digest returns the MAC of the head without its own MAC.
+func (h *Head) digest(key []byte) (string, error) {
+	head := *h
+	head.MAC = ""
+	return mac(key, &head)
+}
+
+func mac(key []byte, value any) (string, error) {
+	if len(key) != KeySize {
+		return "", errors.Errorf("invalid key size: %d", len(key))
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	h := hmac.New(sha256.New, key)
+	_, err = h.Write(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func appendLine(path string, line []byte) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600) // #nosec G304
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(f.Close, &err)
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
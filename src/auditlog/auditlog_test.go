@@ -0,0 +1,292 @@
+package auditlog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, KeySize)
+
+// newLog returns the path of a log with n entries.
+func newLog(t *testing.T, n int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "log")
+	for i := 0; i < n; i++ {
+		err := Append(path, testKey, &Entry{Command: "verify", Path: "/blob", Signer: "signer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(string(data), "\n")
+}
+
+func writeLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeHead(t *testing.T, path string, head *Head) {
+	t.Helper()
+
+	data, err := json.Marshal(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(HeadPath(path), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendVerify(t *testing.T) {
+	path := newLog(t, 3)
+
+	l, err := Verify(path, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 3 || l.Head.Count != 3 || l.Head.Hash != l.Entries[2].Hash {
+		t.Fatalf("got %d entries and head %+v", len(l.Entries), l.Head)
+	}
+
+	for i, entry := range l.Entries {
+		if entry.Seq != i+1 {
+			t.Errorf("entry %d: got sequence number %d", i+1, entry.Seq)
+		}
+		if i > 0 && entry.Prev != l.Entries[i-1].Hash {
+			t.Errorf("entry %d: not chained to the previous entry", i+1)
+		}
+	}
+}
+
+func TestVerifyEmpty(t *testing.T) {
+	l, err := Verify(filepath.Join(t.TempDir(), "log"), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 0 {
+		t.Fatalf("got %d entries", len(l.Entries))
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	path := newLog(t, 2)
+
+	_, err := Verify(path, bytes.Repeat([]byte{0x43}, KeySize))
+	if err == nil {
+		t.Fatal("the log was verified with the wrong key")
+	}
+
+	err = Append(path, bytes.Repeat([]byte{0x43}, KeySize), &Entry{Command: "verify"})
+	if err == nil {
+		t.Fatal("an entry was appended with the wrong key")
+	}
+}
+
+func TestVerifyEdited(t *testing.T) {
+	path := newLog(t, 3)
+	lines := readLines(t, path)
+	writeLines(t, path, []string{lines[0], strings.Replace(lines[1], "/blob", "/blub", 1), lines[2]})
+
+	_, err := Verify(path, testKey)
+	if err == nil {
+		t.Fatal("an edited entry was accepted")
+	}
+}
+
+func TestVerifyRehashed(t *testing.T) {
+	path := newLog(t, 3)
+	lines := readLines(t, path)
+
+	// Edit an entry and fix its hash without the key.
+	entry, err := parseEntry([]byte(strings.TrimSuffix(lines[2], "\n")), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Path = "/blub"
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	entry.Hash = base64.StdEncoding.EncodeToString(sum[:])
+	data, err = json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeLines(t, path, []string{lines[0], lines[1], string(data) + "\n"})
+	writeHead(t, path, &Head{Count: 3, Hash: entry.Hash})
+
+	_, err = Verify(path, testKey)
+	if err == nil {
+		t.Fatal("a rehashed entry was accepted")
+	}
+}
+
+func TestVerifyRemoved(t *testing.T) {
+	path := newLog(t, 3)
+	lines := readLines(t, path)
+	writeLines(t, path, []string{lines[0], lines[2]})
+
+	_, err := Verify(path, testKey)
+	if err == nil {
+		t.Fatal("a log with a removed entry was accepted")
+	}
+}
+
+func TestVerifyReordered(t *testing.T) {
+	path := newLog(t, 3)
+	lines := readLines(t, path)
+	writeLines(t, path, []string{lines[1], lines[0], lines[2]})
+
+	_, err := Verify(path, testKey)
+	if err == nil {
+		t.Fatal("a reordered log was accepted")
+	}
+}
+
+func TestVerifyTruncated(t *testing.T) {
+	path := newLog(t, 3)
+	lines := readLines(t, path)
+	writeLines(t, path, lines[:2])
+
+	_, err := Verify(path, testKey)
+	if err == nil {
+		t.Fatal("a truncated log was accepted")
+	}
+
+	// The head can't be rewritten to match without the key.
+	entry, err := parseEntry([]byte(strings.TrimSuffix(lines[1], "\n")), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeHead(t, path, &Head{Count: 2, Hash: entry.Hash})
+
+	_, err = Verify(path, testKey)
+	if err == nil {
+		t.Fatal("a truncated log with a rewritten head was accepted")
+	}
+}
+
+func TestVerifyPartialEntry(t *testing.T) {
+	path := newLog(t, 2)
+	lines := readLines(t, path)
+	writeLines(t, path, []string{lines[0], lines[1][:len(lines[1])/2]})
+
+	_, err := Verify(path, testKey)
+	if err == nil {
+		t.Fatal("a log with an incomplete entry was accepted")
+	}
+}
+
+func TestVerifyMissingHead(t *testing.T) {
+	path := newLog(t, 2)
+
+	err := os.Remove(HeadPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Verify(path, testKey)
+	if err == nil {
+		t.Fatal("a log without a head was accepted")
+	}
+}
+
+func TestVerifyEntriesAfterHead(t *testing.T) {
+	path := newLog(t, 2)
+
+	head, err := os.ReadFile(HeadPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Append(path, testKey, &Entry{Command: "seal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As if the head couldn't be written after the last entry.
+	err = os.WriteFile(HeadPath(path), head, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Verify(path, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 3 || l.Head.Count != 2 {
+		t.Fatalf("got %d entries and head %+v", len(l.Entries), l.Head)
+	}
+}
+
+func TestKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "key")
+
+	err := CreateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ReadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An existing key is kept.
+	err = CreateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := ReadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Fatal("the key was replaced")
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		t.Fatalf("invalid permissions: %s", stat.Mode())
+	}
+
+	err = os.WriteFile(path, key[1:], 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ReadKey(path)
+	if err == nil {
+		t.Fatal("a short key was accepted")
+	}
+}
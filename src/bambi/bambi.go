@@ -0,0 +1,447 @@
+package bambi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/illikainen/bambi/src/archive"
+	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-cryptor/src/blob"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	blobmeta "github.com/illikainen/go-cryptor/src/metadata"
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+)
+
+type Options struct {
+	Keyring   *blob.Keyring
+	Encrypted bool
+
+	// Labels are embedded in archives created by Seal().
+	Labels map[string]string
+
+	// Expires is how long archives created by Seal() are valid, or 0 if
+	// they don't expire.
+	Expires time.Duration
+
+	// AllowExpired accepts blobs that have expired.
+	AllowExpired bool
+
+	// RequireLabels must be present with the same value in the archive
+	// for Verify(), Unseal(), List(), Get() and Put() to succeed.
+	RequireLabels map[string]string
+
+	// Cosignatures are additional signatures for the blob.
+	Cosignatures *signature.Envelope
+
+	// Policy is checked against the signer and the cosigners of the blob.
+	Policy *Policy
+
+	// TrustedSigners are fingerprints of the keys that are accepted as the
+	// signer of the blob.  Any key in the keyring is accepted if it's empty.
+	TrustedSigners []string
+
+	// Revocations are keys that aren't accepted as signers or cosigners.
+	Revocations *RevocationList
+
+	// ExpectedHashes are compared with the hashes of the whole file by
+	// Verify() and VerifyDetached().
+	ExpectedHashes *ExpectedHashes
+}
+
+type Result struct {
+	Signer   cryptor.PublicKey
+	Signers  []cryptor.PublicKey
+	Metadata *blobmeta.Metadata
+	Labels   map[string]string
+
+	// Created is the signed creation time of the blob.
+	Created time.Time
+
+	// Expires is the signed expiry time of the blob, or the zero time if
+	// it doesn't expire.
+	Expires time.Time
+
+	// Entries is the number of entries in the archive and Size is the
+	// total size of its regular files.
+	Entries int
+	Size    int64
+}
+
+// ErrUntrustedSigner is matched by errors for signers that aren't
+// accepted, either because they aren't trusted, because they're revoked
+// or because there are too few of them.
+var ErrUntrustedSigner = errors.New("untrusted signer")
+
+type untrustedError struct {
+	msg string
+}
+
+func (e *untrustedError) Error() string {
+	return e.msg
+}
+
+func (e *untrustedError) Is(target error) bool {
+	return target == ErrUntrustedSigner
+}
+
+// untrustedf returns an error that matches ErrUntrustedSigner without
+// changing the message.
+func untrustedf(format string, args ...any) error {
+	return errors.WithStack(&untrustedError{msg: fmt.Sprintf(format, args...)})
+}
+
+// SignerError is returned for blobs with a valid signature that fail a
+// later check, so that the signer can be reported.
+type SignerError struct {
+	Signer cryptor.PublicKey
+	err    error
+}
+
+func (e *SignerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *SignerError) Unwrap() error {
+	return e.err
+}
+
+// truncated returns an error that matches iofs.ErrInvalidSize if err is
+// from a blob that ends early.  The signed sizes and hashes can't be
+// checked for such blobs, so they're as invalid as blobs with the wrong
+// size.  Errors from opening or reading the blob are returned as-is.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.Wrapf(iofs.ErrInvalidSize, "the blob is truncated")
+	}
+	return err
+}
+
+// newReader is blob.NewReader() for the blob in r.
+func newReader(r blob.BlobReader, opts *Options) (*blob.Reader, error) {
+	blobber, err := blob.NewReader(r, opts.blobOptions())
+	if err != nil {
+		return nil, truncated(err)
+	}
+	return blobber, nil
+}
+
+func (o *Options) blobOptions() *blob.Options {
+	return &blob.Options{
+		Type:      metadata.Name(),
+		Keyring:   o.Keyring,
+		Encrypted: o.Encrypted,
+	}
+}
+
+func (o *Options) trusted(signer cryptor.PublicKey) bool {
+	if len(o.TrustedSigners) == 0 {
+		return true
+	}
+
+	for _, fpr := range o.TrustedSigners {
+		if signer.Fingerprint() == fpr {
+			return true
+		}
+	}
+	return false
+}
+
+// Seal writes a signed, and optionally encrypted, archive of paths to w.
+func Seal(ctx context.Context, w blob.BlobWriter, paths []string, opts *Options) (err error) {
+	if opts.Expires < 0 {
+		return errors.Errorf("invalid expiry duration: %s", opts.Expires)
+	}
+
+	blobber, err := blob.NewWriter(w, opts.blobOptions())
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(blobber.Close, &err)
+
+	arch, err := archive.NewWriter(blobber)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(arch.Close, &err)
+
+	hdr := &archive.Header{Labels: opts.Labels}
+	if opts.Expires > 0 {
+		hdr.Expires = time.Now().Add(opts.Expires).Unix()
+	}
+
+	err = arch.SetHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	return arch.AddAll(ctx, paths...)
+}
+
+// Verify validates the signature and hashes of the blob in r.
+func Verify(ctx context.Context, r blob.BlobReader, opts *Options) (*Result, error) {
+	res, err := await(ctx, func() (*Result, error) {
+		blobber, err := newReader(r, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return newResult(blobber, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ExpectedHashes != nil {
+		err := checkFile(ctx, r, opts.ExpectedHashes)
+		if err != nil {
+			return nil, &SignerError{Signer: res.Signer, err: err}
+		}
+	}
+
+	return res, nil
+}
+
+// checkFile compares the hashes of the whole file in r with expected.
+func checkFile(ctx context.Context, r blob.BlobReader, expected *ExpectedHashes) error {
+	_, err := iofs.Seek(r, 0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	hashes, err := hash(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	return expected.check(hashes)
+}
+
+// Unseal verifies the blob in r and extracts its archive into dir.
+func Unseal(ctx context.Context, r blob.BlobReader, dir string, opts *Options) (*Result, error) {
+	blobber, err := await(ctx, func() (*blob.Reader, error) {
+		return newReader(r, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := extract(ctx, blobber, dir, opts)
+	if err != nil {
+		return nil, signerError(blobber, err)
+	}
+	return res, nil
+}
+
+func extract(ctx context.Context, blobber *blob.Reader, dir string, opts *Options) (res *Result, err error) {
+	arch, err := archive.NewReader(blobber)
+	if err != nil {
+		return nil, err
+	}
+	defer errorx.Defer(arch.Close, &err)
+
+	res, err = readResult(blobber, arch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = arch.ExtractAll(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// List verifies the blob in r and returns the entries in its archive.
+func List(ctx context.Context, r blob.BlobReader, opts *Options) ([]archive.Entry, error) {
+	return await(ctx, func() ([]archive.Entry, error) {
+		blobber, err := newReader(r, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := listEntries(blobber, opts)
+		if err != nil {
+			return nil, signerError(blobber, err)
+		}
+		return entries, nil
+	})
+}
+
+func listEntries(blobber *blob.Reader, opts *Options) (entries []archive.Entry, err error) {
+	arch, err := archive.NewReader(blobber)
+	if err != nil {
+		return nil, err
+	}
+	defer errorx.Defer(arch.Close, &err)
+
+	_, err = readResult(blobber, arch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return arch.List()
+}
+
+// Get downloads and verifies the blob at uri into rw.
+func Get(ctx context.Context, uri *url.URL, rw blob.BlobReadWriter, opts *Options) (*Result, error) {
+	file := newContextFile(ctx, rw)
+	res, err := await(ctx, func() (*Result, error) {
+		blobber, err := blob.Download(uri, file, opts.blobOptions())
+		if err != nil {
+			return nil, truncated(err)
+		}
+
+		return newResult(blobber, opts)
+	})
+	if err != nil && ctx.Err() != nil {
+		// The download may still be running, but it can't write to rw
+		// once this returns.
+		file.stop()
+	}
+	return res, err
+}
+
+// Put verifies the blob in r and uploads it to uri.
+func Put(ctx context.Context, uri *url.URL, r blob.BlobReader, opts *Options) (*Result, error) {
+	res, err := Verify(ctx, r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = await(ctx, func() (struct{}, error) {
+		return struct{}{}, blob.Upload(uri, r, opts.blobOptions())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// await runs fn and returns early if ctx is cancelled before fn is done.
+// It's used for blob operations that don't support cancellation; the
+// goroutine is left to finish on its own, so files that it writes must be
+// wrapped in a contextFile that is stopped before the caller returns.
+func await[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func newResult(blobber *blob.Reader, opts *Options) (*Result, error) {
+	res, err := checkArchive(blobber, opts)
+	if err != nil {
+		return nil, signerError(blobber, err)
+	}
+	return res, nil
+}
+
+func checkArchive(blobber *blob.Reader, opts *Options) (res *Result, err error) {
+	arch, err := archive.NewReader(blobber)
+	if err != nil {
+		return nil, err
+	}
+	defer errorx.Defer(arch.Close, &err)
+
+	return readResult(blobber, arch, opts)
+}
+
+// signerError returns err for the blob in blobber.  It's used for errors
+// from after the signature of the blob was verified.
+func signerError(blobber *blob.Reader, err error) error {
+	return &SignerError{Signer: blobber.Signer, err: truncated(err)}
+}
+
+// readResult checks the blob in blobber against opts.  The header and the
+// entries of arch are cached, so arch can be extracted afterwards without
+// reading them again.
+func readResult(blobber *blob.Reader, arch *archive.ArchiveReader, opts *Options) (*Result, error) {
+	err := opts.Revocations.check(blobber.Signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.trusted(blobber.Signer) {
+		return nil, untrustedf("%s: untrusted signer", blobber.Signer)
+	}
+
+	hdr, err := arch.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	created := time.Unix(blobber.Metadata.Timestamp, 0)
+	expires := time.Time{}
+	if hdr.Expires > 0 {
+		expires = time.Unix(hdr.Expires, 0)
+		if !opts.AllowExpired && time.Now().After(expires) {
+			return nil, errors.Errorf("the blob expired at %s", formatTime(hdr.Expires))
+		}
+	}
+
+	for key, value := range opts.RequireLabels {
+		actual, ok := hdr.Labels[key]
+		if !ok {
+			return nil, errors.Errorf("%s: missing required label", key)
+		}
+		if actual != value {
+			return nil, errors.Errorf("%s: label mismatch (%s vs %s)", key, actual, value)
+		}
+	}
+
+	all, err := signers(blobber.Signer, blobber.Metadata, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := arch.List()
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(0)
+	for _, entry := range entries {
+		size += entry.Size
+	}
+
+	if opts.Policy != nil {
+		err := opts.Policy.check(all)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{
+		Signer:   blobber.Signer,
+		Signers:  all,
+		Metadata: blobber.Metadata,
+		Labels:   hdr.Labels,
+		Created:  created,
+		Expires:  expires,
+		Entries:  len(entries),
+		Size:     size,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package bambi
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/illikainen/go-cryptor/src/blob"
+)
+
+// contextFile is a blob file that is written by a goroutine started by
+// await().  Every operation fails once ctx is cancelled, and stop() waits
+// for an operation in progress to finish, so that the file isn't written
+// after await() has returned and the caller has removed it.
+type contextFile struct {
+	ctx   context.Context
+	file  blob.BlobReadWriter
+	mutex sync.Mutex
+}
+
+func newContextFile(ctx context.Context, file blob.BlobReadWriter) *contextFile {
+	return &contextFile{ctx: ctx, file: file}
+}
+
+func (f *contextFile) Read(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return f.file.Read(p)
+}
+
+func (f *contextFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return f.file.Write(p)
+}
+
+func (f *contextFile) Seek(offset int64, whence int) (int64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return f.file.Seek(offset, whence)
+}
+
+func (f *contextFile) Truncate(size int64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.ctx.Err()
+	if err != nil {
+		return err
+	}
+	return f.file.Truncate(size)
+}
+
+func (f *contextFile) Sync() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.ctx.Err()
+	if err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+func (f *contextFile) Stat() (os.FileInfo, error) {
+	return f.file.Stat()
+}
+
+func (f *contextFile) Name() string {
+	return f.file.Name()
+}
+
+// stop waits for an operation in progress to finish.  Every later
+// operation fails because ctx is cancelled.
+func (f *contextFile) stop() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+}
@@ -0,0 +1,213 @@
+package bambi
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"path/filepath"
+
+	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-cryptor/src/blob"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-cryptor/src/hasher"
+	blobmeta "github.com/illikainen/go-cryptor/src/metadata"
+	"github.com/illikainen/go-netutils/src/transport"
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+)
+
+// CosignStatement identifies a sealed blob by its signed timestamp and
+// hashes.  Every cosigner of a blob signs an identical statement.
+type CosignStatement struct {
+	Timestamp int64
+	Hashes    *hasher.Writer
+}
+
+// Policy describes the signatures that are required for a blob.
+type Policy struct {
+	// Signers are the fingerprints of acceptable signers.  Any signer in
+	// the keyring is acceptable if it's empty.
+	Signers []string
+
+	// Threshold is the number of distinct acceptable signers that are
+	// required.
+	Threshold int
+
+	// Identities maps the fingerprint of a key to the key that it belongs
+	// to, so that a subkey or a key that replaces another key isn't counted
+	// as another signer.  Keys that aren't in it are their own identity.
+	Identities map[string]string
+}
+
+// CosignType is the envelope type for cosignatures.
+var CosignType = metadata.Name() + ".cosignature"
+
+// CosignExt is appended to the name of a blob to get the name of its
+// cosignatures.
+const CosignExt = ".cosig"
+
+// Cosign verifies the blob in r and adds a signature to its cosignatures.
+// A new set of cosignatures is created if cosig is nil.
+func Cosign(ctx context.Context, r blob.BlobReader, cosig *signature.Envelope,
+	opts *Options) (*signature.Envelope, error) {
+	if opts.Keyring.Private == nil {
+		return nil, errors.Errorf("a private key must be configured to sign")
+	}
+
+	if opts.Revocations.Lookup(opts.Keyring.Private.Fingerprint()) != nil {
+		return nil, errors.Errorf("the private key is revoked")
+	}
+
+	res, err := Verify(ctx, r, &Options{
+		Keyring:       opts.Keyring,
+		Encrypted:     opts.Encrypted,
+		RequireLabels: opts.RequireLabels,
+		Revocations:   opts.Revocations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Signer.Fingerprint() == opts.Keyring.Private.Fingerprint() {
+		return nil, errors.Errorf("the blob is already signed by %s", res.Signer)
+	}
+
+	env, err := newCosignEnvelope(res.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if cosig != nil {
+		if !bytes.Equal(cosig.Payload, env.Payload) {
+			return nil, errors.Errorf("the cosignatures are for another blob")
+		}
+		env = cosig
+	}
+
+	err = env.Sign(opts.Keyring.Private)
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// FetchCosignatures returns the cosignatures for the blob at uri, or nil if
+// the blob doesn't have any cosignatures.
+func FetchCosignatures(ctx context.Context, uri *url.URL) (*signature.Envelope, error) {
+	return await(ctx, func() (env *signature.Envelope, err error) {
+		xfer, err := transport.New(uri)
+		if err != nil {
+			return nil, err
+		}
+		defer errorx.Defer(xfer.Close, &err)
+
+		exists, err := xfer.Exists(uri.Path + CosignExt)
+		if err != nil || !exists {
+			return nil, err
+		}
+
+		tmpDir, tmpClean, err := iofs.MkdirTemp()
+		if err != nil {
+			return nil, err
+		}
+		defer errorx.Defer(tmpClean, &err)
+
+		tmpFile := filepath.Join(tmpDir, "cosig")
+		err = xfer.Download(uri.Path+CosignExt, tmpFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return signature.Read(tmpFile, CosignType)
+	})
+}
+
+// PutCosignatures uploads the cosignatures in path for the blob at uri.
+func PutCosignatures(ctx context.Context, uri *url.URL, path string) error {
+	_, err := await(ctx, func() (_ struct{}, err error) {
+		xfer, err := transport.New(uri)
+		if err != nil {
+			return struct{}{}, err
+		}
+		defer errorx.Defer(xfer.Close, &err)
+
+		return struct{}{}, xfer.Upload(uri.Path+CosignExt, path)
+	})
+	return err
+}
+
+func newCosignEnvelope(meta *blobmeta.Metadata) (*signature.Envelope, error) {
+	return signature.New(CosignType, &CosignStatement{
+		Timestamp: meta.Timestamp,
+		Hashes:    meta.Hashes,
+	})
+}
+
+// signers returns the signer of the blob together with every known
+// cosigner that isn't revoked.
+func signers(signer cryptor.PublicKey, meta *blobmeta.Metadata, opts *Options) ([]cryptor.PublicKey, error) {
+	all := []cryptor.PublicKey{signer}
+	if opts.Cosignatures == nil {
+		return all, nil
+	}
+
+	env, err := newCosignEnvelope(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(opts.Cosignatures.Payload, env.Payload) {
+		return nil, errors.Errorf("the cosignatures are for another blob")
+	}
+
+	cosigners, err := opts.Cosignatures.Signers(opts.Keyring.Public)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cosigner := range cosigners {
+		if cosigner.Fingerprint() != signer.Fingerprint() && opts.Revocations.check(cosigner) == nil {
+			all = append(all, cosigner)
+		}
+	}
+
+	return all, nil
+}
+
+func (p *Policy) check(signers []cryptor.PublicKey) error {
+	identities := map[string]bool{}
+	for _, signer := range signers {
+		if !p.acceptable(signer.Fingerprint()) {
+			continue
+		}
+
+		identity, ok := p.Identities[signer.Fingerprint()]
+		if !ok {
+			identity = signer.Fingerprint()
+		}
+		identities[identity] = true
+	}
+
+	if len(identities) < p.Threshold {
+		return untrustedf("only %d of %d required signatures", len(identities), p.Threshold)
+	}
+
+	return nil
+}
+
+func (p *Policy) acceptable(fpr string) bool {
+	if len(p.Signers) == 0 {
+		return true
+	}
+
+	for _, elt := range p.Signers {
+		if fpr == elt {
+			return true
+		}
+	}
+	return false
+}
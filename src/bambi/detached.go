@@ -0,0 +1,130 @@
+package bambi
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-cryptor/src/hasher"
+	"github.com/pkg/errors"
+)
+
+type DetachedStatement struct {
+	Timestamp int64
+	Hashes    *hasher.Writer
+}
+
+type DetachedResult struct {
+	Signers   []cryptor.PublicKey
+	Statement *DetachedStatement
+}
+
+// DetachedType is the envelope type for detached signatures.
+var DetachedType = metadata.Name() + ".detached-signature"
+
+// Sign creates a detached signature for the content of r.
+func Sign(ctx context.Context, r io.Reader, opts *Options) (*signature.Envelope, error) {
+	if opts.Keyring.Private == nil {
+		return nil, errors.Errorf("a private key must be configured to sign")
+	}
+
+	hashes, err := hash(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := signature.New(DetachedType, &DetachedStatement{
+		Timestamp: time.Now().Unix(),
+		Hashes:    hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.Sign(opts.Keyring.Private)
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// VerifyDetached verifies that sig is a valid signature for the content of
+// r by one of the public keys in the keyring.
+func VerifyDetached(ctx context.Context, r io.Reader, sig *signature.Envelope,
+	opts *Options) (*DetachedResult, error) {
+	if len(opts.Keyring.Public) <= 0 {
+		return nil, errors.Errorf("at least one public key must be configured to verify signatures")
+	}
+
+	signers, err := sig.Verify(opts.Keyring.Public)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := false
+	for _, signer := range signers {
+		err := opts.Revocations.check(signer)
+		if err != nil {
+			return nil, err
+		}
+		trusted = trusted || opts.trusted(signer)
+	}
+	if !trusted {
+		return nil, untrustedf("the signature isn't signed by a trusted signer")
+	}
+
+	stmt := &DetachedStatement{}
+	err = sig.Unmarshal(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Hashes == nil {
+		return nil, errors.Errorf("missing hashes in signature")
+	}
+
+	hashes, err := hash(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	err = hashes.Verify(stmt.Hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = opts.ExpectedHashes.check(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DetachedResult{
+		Signers:   signers,
+		Statement: stmt,
+	}, nil
+}
+
+func hash(ctx context.Context, r io.Reader) (*hasher.Writer, error) {
+	return await(ctx, func() (*hasher.Writer, error) {
+		hashes, err := hasher.NewWriter()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.Copy(hashes, r)
+		if err != nil {
+			return nil, err
+		}
+
+		err = hashes.Finalize()
+		if err != nil {
+			return nil, err
+		}
+
+		return hashes, nil
+	})
+}
@@ -0,0 +1,144 @@
+package bambi
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/illikainen/go-cryptor/src/hasher"
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+// ExpectedHashes are compared with the hashes of a whole blob or file
+// after its signature is verified, so that it can be checked against a
+// published checksum.  Hashes that are nil aren't compared.
+type ExpectedHashes struct {
+	SHA256     []byte
+	BLAKE2b512 []byte
+}
+
+// ParseSHA256 returns the SHA-256 hash in s, in either hex or base64.
+func ParseSHA256(s string) ([]byte, error) {
+	return parseHash(s, sha256.Size)
+}
+
+// ParseBLAKE2b512 returns the BLAKE2b-512 hash in s, in either hex or
+// base64.
+func ParseBLAKE2b512(s string) ([]byte, error) {
+	return parseHash(s, blake2b.Size)
+}
+
+func parseHash(s string, size int) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	data, err := hex.DecodeString(s)
+	if err == nil && len(data) == size {
+		return data, nil
+	}
+
+	data, err = base64.StdEncoding.DecodeString(s)
+	if err == nil && len(data) == size {
+		return data, nil
+	}
+
+	return nil, errors.Errorf("%s: invalid hash (must be %d bytes in hex or base64)", s, size)
+}
+
+// ReadChecksum returns the SHA-256 hash for name in the checksum file in
+// path.  The file is in the format used by sha256sum.  Name is matched by
+// its path, or by its base name if no path matches.  It's an error if more
+// than one entry matches.
+func ReadChecksum(path string, name string) (hash []byte, err error) {
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	defer errorx.Defer(f.Close, &err)
+
+	exact := [][]byte{}
+	base := [][]byte{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("%s: invalid line: %s", path, line)
+		}
+
+		// Binary mode is marked with a '*' before the name.
+		file := strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*")
+		isExact := filepath.Clean(file) == filepath.Clean(name)
+		if !isExact && filepath.Base(file) != filepath.Base(name) {
+			continue
+		}
+
+		hash, err := ParseSHA256(fields[0])
+		if err != nil {
+			return nil, errors.Errorf("%s: %s", path, err)
+		}
+
+		if isExact {
+			exact = append(exact, hash)
+		} else {
+			base = append(base, hash)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := exact
+	if len(matches) == 0 {
+		matches = base
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errors.Errorf("%s: no checksum for %s", path, name)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, errors.Errorf("%s: ambiguous checksums for %s (%d entries)", path, name, len(matches))
+}
+
+func (e *ExpectedHashes) check(hashes *hasher.Writer) error {
+	if e == nil {
+		return nil
+	}
+
+	err := compareHash("sha2-256", hashes.SHA256, e.SHA256)
+	if err != nil {
+		return err
+	}
+
+	return compareHash("blake2b-512", hashes.BLAKE2b512, e.BLAKE2b512)
+}
+
+func compareHash(name string, hash string, expected []byte) error {
+	if expected == nil {
+		return nil
+	}
+
+	actual, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return errors.Wrapf(hasher.ErrInvalidHash, "%s", name)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		return errors.Wrapf(hasher.ErrInvalidHash, "%s mismatch (%x vs %x)", name, actual, expected)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package bambi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	sumA = "0000000000000000000000000000000000000000000000000000000000000000"
+	sumB = "1111111111111111111111111111111111111111111111111111111111111111"
+)
+
+func writeChecksums(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "SHA256SUMS")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadChecksum(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		input string
+		want  string
+	}{
+		{"exact", []string{sumA + "  a/x.bin", sumB + "  b/x.bin"}, "b/x.bin", sumB},
+		{"clean", []string{sumA + "  ./a/x.bin", sumB + "  b/x.bin"}, "a/x.bin", sumA},
+		{"binary", []string{sumA + " *x.bin"}, "x.bin", sumA},
+		{"base name", []string{"# comment", "", sumA + "  y.bin", sumB + "  dist/x.bin"}, "/tmp/x.bin", sumB},
+		{"exact before base name", []string{sumA + "  dist/x.bin", sumB + "  x.bin"}, "x.bin", sumB},
+	}
+
+	for _, test := range tests {
+		hash, err := ReadChecksum(writeChecksums(t, test.lines...), test.input)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		want, err := ParseSHA256(test.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(hash, want) {
+			t.Errorf("%s: got %x, want %s", test.name, hash, test.want)
+		}
+	}
+}
+
+func TestReadChecksumInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		input string
+	}{
+		{"missing", []string{sumA + "  y.bin"}, "x.bin"},
+		{"ambiguous base name", []string{sumA + "  a/x.bin", sumB + "  b/x.bin"}, "x.bin"},
+		{"ambiguous path", []string{sumA + "  x.bin", sumB + "  x.bin"}, "x.bin"},
+		{"invalid hash", []string{"abcd  x.bin"}, "x.bin"},
+		{"invalid line", []string{sumA}, "x.bin"},
+	}
+
+	for _, test := range tests {
+		_, err := ReadChecksum(writeChecksums(t, test.lines...), test.input)
+		if err == nil {
+			t.Errorf("%s: ReadChecksum succeeded", test.name)
+		}
+	}
+}
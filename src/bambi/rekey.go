@@ -0,0 +1,151 @@
+package bambi
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/illikainen/go-cryptor/src/blob"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-cryptor/src/symmetric"
+	"github.com/pkg/errors"
+)
+
+// Recipients describes how the recipients of a blob are changed by Rekey().
+type Recipients struct {
+	// Add are public keys to encrypt the blob for in addition to the
+	// current recipients.
+	Add []cryptor.PublicKey
+
+	// Remove are fingerprints of current recipients that shouldn't be
+	// able to decrypt the blob after it's rekeyed.
+	Remove []string
+}
+
+// Rekey decrypts the blob in r and writes it to w encrypted for a new set
+// of recipients and signed by the private key in opts.Keyring.  The archive
+// in the blob is copied as-is without being unpacked.
+//
+// Every recipient that is kept must be in opts.Keyring.  Note that the
+// cosignatures of the original blob aren't valid for the rekeyed blob.
+func Rekey(ctx context.Context, r blob.BlobReader, w blob.BlobWriter, recipients *Recipients,
+	opts *Options) (res *Result, err error) {
+	if !opts.Encrypted {
+		return nil, errors.Errorf("signed-only blobs can't be rekeyed")
+	}
+
+	reader, err := await(ctx, func() (*blob.Reader, error) {
+		return newReader(r, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = newResult(reader, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := rekeyRecipients(reader.Metadata.Keys, recipients, opts.Keyring.Public)
+	if err != nil {
+		return nil, err
+	}
+
+	wopts := *opts
+	wopts.Keyring = &blob.Keyring{
+		Public:  public,
+		Private: opts.Keyring.Private,
+	}
+
+	writer, err := blob.NewWriter(w, wopts.blobOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	err = copyContext(ctx, writer, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// rekeyRecipients returns the public keys for the current recipients of a
+// blob, with the changes in recipients applied.
+func rekeyRecipients(current map[string]*symmetric.Keys, recipients *Recipients,
+	keys []cryptor.PublicKey) ([]cryptor.PublicKey, error) {
+	remove := map[string]bool{}
+	for _, fpr := range recipients.Remove {
+		if _, ok := current[fpr]; !ok {
+			return nil, errors.Errorf("%s: not a recipient of the blob", fpr)
+		}
+		remove[fpr] = true
+	}
+
+	fprs := []string{}
+	for fpr := range current {
+		if !remove[fpr] {
+			fprs = append(fprs, fpr)
+		}
+	}
+	sort.Strings(fprs)
+
+	public := []cryptor.PublicKey{}
+	for _, fpr := range fprs {
+		key := findKey(keys, fpr)
+		if key == nil {
+			return nil, errors.Errorf("%s: recipient isn't in the keyring", fpr)
+		}
+		public = append(public, key)
+	}
+
+	for _, key := range recipients.Add {
+		if remove[key.Fingerprint()] {
+			return nil, errors.Errorf("%s: recipient is both added and removed", key.Fingerprint())
+		}
+		if findKey(public, key.Fingerprint()) == nil {
+			public = append(public, key)
+		}
+	}
+
+	return public, nil
+}
+
+func findKey(keys []cryptor.PublicKey, fpr string) cryptor.PublicKey {
+	for _, key := range keys {
+		if key.Fingerprint() == fpr {
+			return key
+		}
+	}
+	return nil
+}
+
+// copyContext is like io.Copy() but it stops when ctx is done.
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader) error {
+	buf := make([]byte, blob.ChunkSize)
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			_, werr := dst.Write(buf[:n])
+			if werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
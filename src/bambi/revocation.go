@@ -0,0 +1,154 @@
+package bambi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-utils/src/seq"
+	"github.com/pkg/errors"
+)
+
+// Revocation is a revoked key.  Timestamp is the time when the key was
+// added to the list, not when it was compromised.
+type Revocation struct {
+	Fingerprint string
+	Timestamp   int64
+	Reason      string
+}
+
+// RevocationList is a list of keys that shouldn't be trusted.  Blobs and
+// signatures by a revoked key are refused regardless of when they claim to
+// have been signed because the timestamps are chosen by the signer.
+type RevocationList struct {
+	Revocations []*Revocation
+}
+
+// RevocationType is the envelope type for revocation lists.
+var RevocationType = metadata.Name() + ".revocations"
+
+// ReadRevocations reads a revocation list that must be signed by one of
+// the public keys in keys with a fingerprint in authorities.  Lists that
+// are signed by a key that they revoke are refused, because the holder of
+// a compromised key could otherwise leave it out of the list.
+func ReadRevocations(path string, keys []cryptor.PublicKey, authorities []string) (*RevocationList, error) {
+	env, err := signature.Read(path, RevocationType)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, err := env.Verify(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &RevocationList{}
+	err = env.Unmarshal(list)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := 0
+	for _, signer := range signers {
+		if !seq.Contains(authorities, signer.Fingerprint()) {
+			continue
+		}
+
+		err := list.check(signer)
+		if err != nil {
+			return nil, errors.Errorf("%s: signed by a revoked key: %s", path, err)
+		}
+		trusted++
+	}
+
+	if trusted == 0 {
+		return nil, untrustedf("%s: the revocation list has an untrusted signer", path)
+	}
+
+	return list, nil
+}
+
+// Revoke adds fpr to the list.
+func (l *RevocationList) Revoke(fpr string, timestamp int64, reason string) error {
+	if fpr == "" {
+		return errors.Errorf("invalid fingerprint")
+	}
+
+	rev := l.Lookup(fpr)
+	if rev != nil {
+		return errors.Errorf("%s: already revoked at %s", fpr, formatTime(rev.Timestamp))
+	}
+
+	l.Revocations = append(l.Revocations, &Revocation{
+		Fingerprint: fpr,
+		Timestamp:   timestamp,
+		Reason:      reason,
+	})
+	return nil
+}
+
+// Lookup returns the revocation for fpr, or nil if it isn't revoked.
+func (l *RevocationList) Lookup(fpr string) *Revocation {
+	if l == nil {
+		return nil
+	}
+
+	for _, rev := range l.Revocations {
+		if rev.Fingerprint == fpr {
+			return rev
+		}
+	}
+	return nil
+}
+
+// Sign returns the list in an envelope signed by key.
+func (l *RevocationList) Sign(key cryptor.PrivateKey) (*signature.Envelope, error) {
+	env, err := signature.New(RevocationType, l)
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.Sign(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+func (l *RevocationList) check(key cryptor.PublicKey) error {
+	fprs := []string{key.Fingerprint()}
+
+	// Subkeys are revoked together with their primary key, and keys that
+	// replace another key through a transition are revoked together with
+	// their predecessors.  Otherwise, anyone holding a revoked key could
+	// keep using it through a subkey or a transition of their own.
+	if linked, ok := key.(interface{ Lineage() []string }); ok {
+		fprs = append(fprs, linked.Lineage()...)
+	}
+
+	for _, fpr := range fprs {
+		rev := l.Lookup(fpr)
+		if rev == nil {
+			continue
+		}
+
+		via := ""
+		if fpr != key.Fingerprint() {
+			via = fmt.Sprintf(" through %s", fpr)
+		}
+
+		if rev.Reason == "" {
+			return untrustedf("%s: key was revoked%s at %s", key, via, formatTime(rev.Timestamp))
+		}
+		return untrustedf("%s: key was revoked%s at %s: %s", key, via, formatTime(rev.Timestamp), rev.Reason)
+	}
+	return nil
+}
+
+func formatTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,68 @@
+package bambi
+
+import (
+	"testing"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/pkg/errors"
+)
+
+// fakeKey is a public key that only has a fingerprint and a lineage.
+type fakeKey struct {
+	cryptor.PublicKey
+	fpr     string
+	lineage []string
+}
+
+func (f *fakeKey) Fingerprint() string {
+	return f.fpr
+}
+
+func (f *fakeKey) String() string {
+	return f.fpr
+}
+
+func (f *fakeKey) Lineage() []string {
+	return f.lineage
+}
+
+func TestRevocationCheck(t *testing.T) {
+	list := &RevocationList{}
+	err := list.Revoke("old", 0, "compromised")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = list.Revoke("old", 0, "")
+	if err == nil {
+		t.Fatal("a key was revoked twice")
+	}
+
+	tests := []struct {
+		key     *fakeKey
+		revoked bool
+	}{
+		{&fakeKey{fpr: "old"}, true},
+		{&fakeKey{fpr: "new", lineage: []string{"old"}}, true},
+		{&fakeKey{fpr: "newer", lineage: []string{"new", "old"}}, true},
+		{&fakeKey{fpr: "subkey", lineage: []string{"newer", "new", "old"}}, true},
+		{&fakeKey{fpr: "other"}, false},
+		{&fakeKey{fpr: "other-subkey", lineage: []string{"other"}}, false},
+	}
+
+	for _, test := range tests {
+		err := list.check(test.key)
+		if test.revoked && !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("%s: got %v, want a revoked key", test.key, err)
+		}
+		if !test.revoked && err != nil {
+			t.Errorf("%s: %s", test.key, err)
+		}
+	}
+
+	var nilList *RevocationList
+	err = nilList.check(&fakeKey{fpr: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,473 @@
+package bambi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/shamir"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	blobmeta "github.com/illikainen/go-cryptor/src/metadata"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/stringx"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Threshold encryption splits each symmetric key of a blob with Shamir's
+// secret sharing and encrypts one share for each recipient.  The encrypted
+// shares are stored in the metadata as the keys of a single pseudo-recipient
+// so that the blob format is unchanged.  Each recipient decrypts their own
+// share with DecryptShare(), and any threshold of the decrypted shares can
+// be combined with a ThresholdKey to unseal the blob.
+const (
+	thresholdFormat       = "bambi.threshold"
+	decryptionShareFormat = "bambi.decryption-share"
+	thresholdPrefix       = "threshold:"
+	thresholdIDSize       = 16
+)
+
+type thresholdCiphertext struct {
+	Format    string
+	ID        []byte
+	Threshold int
+	Shares    []*thresholdShare
+}
+
+type thresholdShare struct {
+	Recipient  string
+	X          byte
+	Ciphertext string
+}
+
+// ThresholdRecipient is a public key that encrypts for a group of
+// recipients of which Threshold must cooperate to decrypt.
+type ThresholdRecipient struct {
+	Threshold  int
+	Recipients []cryptor.PublicKey
+}
+
+// NewThresholdRecipient returns a recipient that requires threshold of
+// recipients to decrypt.
+func NewThresholdRecipient(recipients []cryptor.PublicKey, threshold int) (*ThresholdRecipient, error) {
+	if threshold < shamir.MinShares || threshold > len(recipients) || len(recipients) > shamir.MaxShares {
+		return nil, errors.Errorf("invalid threshold: %d of %d recipients", threshold, len(recipients))
+	}
+
+	seen := map[string]bool{}
+	for _, key := range recipients {
+		if seen[key.Fingerprint()] {
+			return nil, errors.Errorf("%s: duplicate recipient", key.Fingerprint())
+		}
+		seen[key.Fingerprint()] = true
+	}
+
+	return &ThresholdRecipient{Threshold: threshold, Recipients: recipients}, nil
+}
+
+func (t *ThresholdRecipient) Encrypt(plaintext []byte) (string, error) {
+	parts, err := shamir.Split(plaintext, len(t.Recipients), t.Threshold)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		for _, part := range parts {
+			wipe(part.Data)
+		}
+	}()
+
+	ct := &thresholdCiphertext{
+		Format:    thresholdFormat,
+		ID:        make([]byte, thresholdIDSize),
+		Threshold: t.Threshold,
+	}
+
+	_, err = rand.Read(ct.ID)
+	if err != nil {
+		return "", err
+	}
+
+	for i, key := range t.Recipients {
+		encrypted, err := key.Encrypt(parts[i].Data)
+		if err != nil {
+			return "", err
+		}
+
+		ct.Shares = append(ct.Shares, &thresholdShare{
+			Recipient:  key.Fingerprint(),
+			X:          parts[i].X,
+			Ciphertext: encrypted,
+		})
+	}
+
+	data, err := json.Marshal(ct)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (t *ThresholdRecipient) Verify([]byte, []byte) error {
+	return cryptor.ErrNotImplemented
+}
+
+func (t *ThresholdRecipient) UnmarshalJSON([]byte) error {
+	return cryptor.ErrNotImplemented
+}
+
+func (t *ThresholdRecipient) Write(string) error {
+	return errors.Errorf("threshold recipients can't be written")
+}
+
+// Fingerprint identifies the threshold and the set of recipients,
+// regardless of the order of the recipients.
+func (t *ThresholdRecipient) Fingerprint() string {
+	fprs := []string{}
+	for _, key := range t.Recipients {
+		fprs = append(fprs, key.Fingerprint())
+	}
+	sort.Strings(fprs)
+
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s", t.Threshold, strings.Join(fprs, "\n"))))
+	return fmt.Sprintf("%s%d-of-%d:%s", thresholdPrefix, t.Threshold, len(fprs),
+		base64.StdEncoding.EncodeToString(digest[:]))
+}
+
+func (t *ThresholdRecipient) String() string {
+	return t.Fingerprint()
+}
+
+// DecryptionShare is the share of the symmetric keys of a blob that one
+// recipient has decrypted.
+type DecryptionShare struct {
+	Format      string
+	Fingerprint string
+	Recipient   string
+	Threshold   int
+	Count       int
+	Parts       []*decryptedPart
+}
+
+type decryptedPart struct {
+	ID   []byte
+	X    byte
+	Data []byte
+}
+
+// DecryptShare verifies the signature of the blob in r and decrypts the
+// share of its symmetric keys that belongs to the private key in
+// opts.Keyring.  The share can't decrypt the blob on its own.
+func DecryptShare(ctx context.Context, r io.Reader, opts *Options) (*DecryptionShare, error) {
+	if opts.Keyring.Private == nil {
+		return nil, errors.Errorf("a private key must be configured to decrypt")
+	}
+
+	hdr, err := await(ctx, func() (*header, error) {
+		return readHeader(r, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = opts.Revocations.check(hdr.signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.trusted(hdr.signer) {
+		return nil, untrustedf("%s: untrusted signer", hdr.signer)
+	}
+
+	meta := hdr.meta
+
+	fpr := ""
+	for elt := range meta.Keys {
+		if strings.HasPrefix(elt, thresholdPrefix) {
+			fpr = elt
+		}
+	}
+	if fpr == "" {
+		return nil, errors.Errorf("the blob isn't encrypted with a threshold")
+	}
+
+	share := &DecryptionShare{
+		Format:      decryptionShareFormat,
+		Fingerprint: fpr,
+		Recipient:   opts.Keyring.Private.Fingerprint(),
+	}
+
+	keys := meta.Keys[fpr]
+	for _, ciphertext := range []string{keys.XChaCha20Poly1305, keys.AESGCM} {
+		ct, err := readThresholdCiphertext(ciphertext)
+		if err != nil {
+			return nil, err
+		}
+
+		part, err := decryptPart(ct, opts.Keyring.Private)
+		if err != nil {
+			return nil, err
+		}
+
+		share.Threshold = ct.Threshold
+		share.Count = len(ct.Shares)
+		share.Parts = append(share.Parts, part)
+	}
+
+	return share, nil
+}
+
+func decryptPart(ct *thresholdCiphertext, key cryptor.PrivateKey) (*decryptedPart, error) {
+	for _, elt := range ct.Shares {
+		if elt.Recipient == key.Fingerprint() {
+			data, err := key.Decrypt(elt.Ciphertext)
+			if err != nil {
+				return nil, err
+			}
+			return &decryptedPart{ID: ct.ID, X: elt.X, Data: data}, nil
+		}
+	}
+	return nil, errors.Errorf("%s: not a recipient of the blob", key.Fingerprint())
+}
+
+type header struct {
+	meta   *blobmeta.Metadata
+	signer cryptor.PublicKey
+}
+
+// readHeader reads the metadata of an encrypted blob and verifies its
+// signature in the same way as blob.NewReader(), without decrypting the
+// symmetric keys.
+func readHeader(r io.Reader, opts *Options) (*header, error) {
+	metaBytes, sig, err := readRawHeader(r)
+	if err != nil {
+		return nil, truncated(err)
+	}
+
+	for _, pubKey := range opts.Keyring.Public {
+		if pubKey.Verify(metaBytes, sig) == nil {
+			meta, err := blobmeta.Read(metaBytes, metadata.Name(), true)
+			if err != nil {
+				return nil, err
+			}
+			return &header{meta: meta, signer: pubKey}, nil
+		}
+	}
+
+	return nil, errors.Wrapf(cryptor.ErrInvalidSignature, "could not verify signature")
+}
+
+// ReadMetadata returns the metadata of the blob in r without verifying its
+// signature.  It's only meant for blobs that were just sealed.
+func ReadMetadata(r io.Reader, encrypted bool) (*blobmeta.Metadata, error) {
+	metaBytes, _, err := readRawHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return blobmeta.Read(metaBytes, metadata.Name(), encrypted)
+}
+
+func readRawHeader(r io.Reader) ([]byte, []byte, error) {
+	size := uint32(0)
+	sizeBytes := make([]byte, unsafe.Sizeof(size)) // #nosec G103
+	err := iofs.ReadFull(r, sizeBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = binary.Read(bytes.NewReader(sizeBytes), binary.BigEndian, &size)
+	if err != nil {
+		return nil, nil, err
+	}
+	if size == 0 {
+		return nil, nil, errors.Errorf("invalid metadata size: %d", size)
+	}
+
+	metaBytes := make([]byte, size)
+	err = iofs.ReadFull(r, metaBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sig := make([]byte, asymmetric.SignatureSize)
+	err = iofs.ReadFull(r, sig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return metaBytes, sig, nil
+}
+
+func readThresholdCiphertext(ciphertext string) (*thresholdCiphertext, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	ct := &thresholdCiphertext{}
+	err = json.Unmarshal(data, ct)
+	if err != nil {
+		return nil, err
+	}
+
+	if ct.Format != thresholdFormat {
+		return nil, errors.Errorf("invalid threshold ciphertext")
+	}
+
+	return ct, nil
+}
+
+// ReadDecryptionShare reads a share that was written by
+// DecryptionShare.Write().
+func ReadDecryptionShare(path string) (*DecryptionShare, error) {
+	data, err := iofs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(stringx.Sanitize(data), data) {
+		return nil, errors.Errorf("%s contains invalid characters", path)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	share := &DecryptionShare{}
+	err = json.Unmarshal(decoded, share)
+	if err != nil {
+		return nil, err
+	}
+
+	if share.Format != decryptionShareFormat {
+		return nil, errors.Errorf("%s: not a decryption share", path)
+	}
+
+	return share, nil
+}
+
+func (s *DecryptionShare) Write(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	encoded := append([]byte(base64.StdEncoding.EncodeToString(data)), '\n')
+	err = os.WriteFile(path, encoded, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("%s: wrote decryption share for %s", path, s.Recipient)
+	return nil
+}
+
+// ThresholdKey is a private key that decrypts blobs that were encrypted
+// for a ThresholdRecipient by combining the decryption shares of enough
+// recipients.
+type ThresholdKey struct {
+	shares []*DecryptionShare
+}
+
+// NewThresholdKey returns a key for shares, which must be for the same
+// threshold recipient and from different recipients.
+func NewThresholdKey(shares []*DecryptionShare) (*ThresholdKey, error) {
+	if len(shares) == 0 {
+		return nil, errors.Errorf("no shares")
+	}
+
+	first := shares[0]
+	seen := map[string]bool{}
+	for _, share := range shares {
+		if share.Fingerprint != first.Fingerprint {
+			return nil, errors.Errorf("the shares are for different recipients")
+		}
+		if seen[share.Recipient] {
+			return nil, errors.Errorf("%s: duplicate share", share.Recipient)
+		}
+		seen[share.Recipient] = true
+	}
+
+	if len(shares) < first.Threshold {
+		return nil, errors.Errorf("%d of %d shares are required", first.Threshold, first.Count)
+	}
+
+	return &ThresholdKey{shares: shares}, nil
+}
+
+func (t *ThresholdKey) Sign([]byte) ([]byte, error) {
+	return nil, cryptor.ErrNotImplemented
+}
+
+func (t *ThresholdKey) Decrypt(ciphertext string) ([]byte, error) {
+	ct, err := readThresholdCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []*shamir.Share{}
+	for _, share := range t.shares {
+		part := share.lookup(ct)
+		if part == nil {
+			return nil, errors.Errorf("%s: the share is for another blob", share.Recipient)
+		}
+		parts = append(parts, &shamir.Share{X: part.X, Data: part.Data})
+	}
+
+	return shamir.Combine(parts)
+}
+
+// lookup returns the part of the share that was decrypted from ct, or nil
+// if it's missing.  The x-coordinate must match that of the recipient.
+func (s *DecryptionShare) lookup(ct *thresholdCiphertext) *decryptedPart {
+	for _, part := range s.Parts {
+		if !bytes.Equal(part.ID, ct.ID) {
+			continue
+		}
+
+		for _, elt := range ct.Shares {
+			if elt.Recipient == s.Recipient && elt.X == part.X {
+				return part
+			}
+		}
+	}
+	return nil
+}
+
+func (t *ThresholdKey) UnmarshalJSON([]byte) error {
+	return cryptor.ErrNotImplemented
+}
+
+func (t *ThresholdKey) Write(string) error {
+	return errors.Errorf("threshold keys can't be written")
+}
+
+func (t *ThresholdKey) Fingerprint() string {
+	return t.shares[0].Fingerprint
+}
+
+func (t *ThresholdKey) String() string {
+	return t.Fingerprint()
+}
+
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
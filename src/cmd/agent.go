@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/illikainen/bambi/src/agent"
+	"github.com/illikainen/bambi/src/keyring"
+	"github.com/illikainen/bambi/src/metadata"
+
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var agentOpts struct {
+	socket  string
+	timeout time.Duration
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Hold the private key for other commands",
+	Long: "Hold the private key for other commands.\n\n" +
+		"The agent unlocks the configured private key and answers sign and\n" +
+		"decrypt requests on a unix socket.  Other commands use the agent instead\n" +
+		"of the private key file if " + agent.SockEnv + " is set to the path of\n" +
+		"the socket.\n",
+	PreRunE: agentPreRun,
+	RunE:    agentRun,
+}
+
+func init() {
+	flags := agentCmd.Flags()
+
+	flags.StringVarP(&agentOpts.socket, "socket", "", "",
+		fmt.Sprintf("Path for the agent socket (default: %s)", defaultAgentSocket()))
+	flags.DurationVarP(&agentOpts.timeout, "timeout", "t", time.Hour,
+		"Drop the private key and exit after this duration (0 to never time out)")
+
+	rootCmd.AddCommand(agentCmd)
+}
+
+func agentPreRun(_ *cobra.Command, _ []string) error {
+	if rootOpts.PrivKey == "" {
+		return errors.Errorf("a private key must be configured for the agent")
+	}
+
+	if agentOpts.socket == "" {
+		agentOpts.socket = defaultAgentSocket()
+
+		// The default directory may be in a shared location such as
+		// /tmp, so it must be private to the current user.
+		err := agent.MkdirPrivate(filepath.Dir(agentOpts.socket))
+		if err != nil {
+			return err
+		}
+	}
+
+	socket, err := filepath.Abs(agentOpts.socket)
+	if err != nil {
+		return err
+	}
+	agentOpts.socket = socket
+
+	err = os.MkdirAll(filepath.Dir(agentOpts.socket), 0700)
+	if err != nil {
+		return err
+	}
+
+	rootOpts.passphrase, err = readKeyPassphrase(rootOpts.PrivKey)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(rootOpts.PrivKey)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(filepath.Dir(agentOpts.socket))
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func agentRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	path, err := iofs.Expand(rootOpts.PrivKey)
+	if err != nil {
+		return err
+	}
+
+	key, err := keyring.ReadPrivateKey(path, rootOpts.passphrase)
+	if err != nil {
+		return err
+	}
+
+	server, err := agent.Listen(agentOpts.socket, key)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("holding %s", key)
+	log.Infof("export %s=%s", agent.SockEnv, agentOpts.socket)
+	if agentOpts.timeout > 0 {
+		log.Infof("the key is dropped after %s", agentOpts.timeout)
+	}
+
+	return server.Serve(cmd.Context(), agentOpts.timeout)
+}
+
+// agentSocket returns the path of the agent socket, or an empty string if
+// the agent isn't used.
+func agentSocket() string {
+	return os.Getenv(agent.SockEnv)
+}
+
+func defaultAgentSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", metadata.Name(), os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, metadata.Name())
+	}
+	return filepath.Join(dir, "agent.sock")
+}
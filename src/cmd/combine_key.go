@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var combineKeyOpts struct {
+	output     string
+	passphrase bool
+	newPass    []byte
+}
+
+var combineKeyCmd = &cobra.Command{
+	Use:   "combine-key [flags] <share>...",
+	Short: "Combine shares into a private key",
+	Long: "Combine shares into a private key.\n\n" +
+		"The shares must have been written by split-key.  The combined key is\n" +
+		"verified against the fingerprint of the key that was split, and it's\n" +
+		"written with its public key and metadata to <output>.pub and\n" +
+		"<output>.priv.\n",
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: combineKeyPreRun,
+	RunE:    combineKeyRun,
+}
+
+func init() {
+	flags := combineKeyCmd.Flags()
+
+	flags.StringVarP(&combineKeyOpts.output, "output", "o", "",
+		"Write the combined keypair to <output>.pub and <output>.priv")
+	fn.Must(combineKeyCmd.MarkFlagRequired("output"))
+
+	flags.BoolVarP(&combineKeyOpts.passphrase, "passphrase", "", false,
+		"Protect the private key with a passphrase")
+
+	rootCmd.AddCommand(combineKeyCmd)
+}
+
+func combineKeyPreRun(_ *cobra.Command, args []string) (err error) {
+	if combineKeyOpts.passphrase {
+		combineKeyOpts.newPass, err = readNewPassphrase("Passphrase")
+		if err != nil {
+			return err
+		}
+
+		if len(combineKeyOpts.newPass) == 0 {
+			return errors.Errorf("the passphrase can't be empty")
+		}
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(args...)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(combineKeyOpts.output+".pub", combineKeyOpts.output+".priv")
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func combineKeyRun(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	shares := []*keyring.KeyShare{}
+	for _, path := range args {
+		share, err := keyring.ReadKeyShare(path)
+		if err != nil {
+			return err
+		}
+		log.Infof("%s: share %d of %d for %s", path, share.X, share.Count, share.Fingerprint)
+		shares = append(shares, share)
+	}
+
+	key, meta, err := keyring.CombinePrivateKey(shares)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := keyring.PublicKeyFor(key)
+	if err != nil {
+		return err
+	}
+
+	return writeKeypair(combineKeyOpts.output, pubKey, key, combineKeyOpts.newPass, meta)
+}
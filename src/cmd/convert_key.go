@@ -1,16 +1,21 @@
 package cmd
 
 import (
+	"github.com/illikainen/bambi/src/keyring"
+
 	"github.com/illikainen/go-cryptor/src/asymmetric"
 	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var convertKeyOpts struct {
-	input   string
-	output  string
-	private bool
+	input      string
+	output     string
+	private    bool
+	passphrase bool
+	newPass    []byte
 }
 
 var convertKeyCmd = &cobra.Command{
@@ -31,11 +36,29 @@ func init() {
 	fn.Must(convertKeyCmd.MarkFlagRequired("output"))
 
 	flags.BoolVarP(&convertKeyOpts.private, "private", "P", false, "Treat the key as a private key")
+	flags.BoolVarP(&convertKeyOpts.passphrase, "passphrase", "", false,
+		"Protect the converted private key with a passphrase")
 
 	rootCmd.AddCommand(convertKeyCmd)
 }
 
 func convertKeyPreRun(_ *cobra.Command, _ []string) error {
+	if convertKeyOpts.passphrase {
+		if !convertKeyOpts.private {
+			return errors.Errorf("only private keys can be protected with a passphrase")
+		}
+
+		passphrase, err := readNewPassphrase("Passphrase")
+		if err != nil {
+			return err
+		}
+
+		if len(passphrase) == 0 {
+			return errors.Errorf("the passphrase can't be empty")
+		}
+		convertKeyOpts.newPass = passphrase
+	}
+
 	err := rootOpts.Sandbox.AddReadOnlyPath(convertKeyOpts.input)
 	if err != nil {
 		return err
@@ -60,7 +83,7 @@ func convertKeyRun(cmd *cobra.Command, _ []string) (err error) {
 		}
 
 		fingerprint = key.Fingerprint()
-		err = key.Write(convertKeyOpts.output)
+		err = keyring.WritePrivateKey(convertKeyOpts.output, key, convertKeyOpts.newPass)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/illikainen/bambi/src/bambi"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/fn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cosignOpts struct {
+	input      string
+	output     string
+	signedOnly bool
+}
+
+var cosignCmd = &cobra.Command{
+	Use:   "cosign",
+	Short: "Add a cosignature to a sealed blob",
+	Long: "Add a cosignature to a sealed blob.\n\n" +
+		"The blob is verified before it's cosigned.  Existing cosignatures in\n" +
+		"the output file are kept.\n",
+	PreRunE: cosignPreRun,
+	RunE:    cosignRun,
+}
+
+func init() {
+	flags := cosignCmd.Flags()
+
+	flags.StringVarP(&cosignOpts.input, "input", "i", "", "Sealed blob to cosign")
+	fn.Must(cosignCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&cosignOpts.output, "output", "o", "",
+		"Output file for the cosignatures (default: <input>"+bambi.CosignExt+")")
+	flags.BoolVarP(&cosignOpts.signedOnly, "signed-only", "", false,
+		"Cosign a signed but unencrypted blob")
+
+	rootCmd.AddCommand(cosignCmd)
+}
+
+func cosignPreRun(_ *cobra.Command, _ []string) error {
+	if cosignOpts.output == "" {
+		cosignOpts.output = cosignOpts.input + bambi.CosignExt
+	}
+
+	err := rootOpts.Sandbox.AddReadOnlyPath(cosignOpts.input)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(cosignOpts.output)
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func cosignRun(cmd *cobra.Command, _ []string) (err error) {
+	cmd.SilenceUsage = true
+
+	keys, err := readBlobKeyring()
+	if err != nil {
+		return err
+	}
+
+	revocations, err := readRevocations(keys)
+	if err != nil {
+		return err
+	}
+
+	cosig, err := readCosignatures(cosignOpts.output)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(cosignOpts.input)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(f.Close, &err)
+
+	cosig, err = bambi.Cosign(cmd.Context(), f, cosig, &bambi.Options{
+		Keyring:     keys,
+		Encrypted:   !cosignOpts.signedOnly,
+		Revocations: revocations,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = cosig.Write(cosignOpts.output)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("successfully wrote cosignatures for %s to %s", cosignOpts.input, cosignOpts.output)
+	return nil
+}
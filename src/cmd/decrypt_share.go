@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/illikainen/bambi/src/bambi"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/fn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var decryptShareOpts struct {
+	input  string
+	output string
+}
+
+var decryptShareCmd = &cobra.Command{
+	Use:   "decrypt-share",
+	Short: "Decrypt your share of a threshold-encrypted blob",
+	Long: "Decrypt your share of a threshold-encrypted blob.\n\n" +
+		"Blobs that were sealed with `seal --threshold` can only be unsealed\n" +
+		"with the shares of enough recipients.  The signature of the blob is\n" +
+		"verified before the share is decrypted.  The share can't decrypt the\n" +
+		"blob on its own, but it should still be given only to whoever\n" +
+		"combines the shares with `unseal --share`.\n",
+	PreRunE: decryptSharePreRun,
+	RunE:    decryptShareRun,
+}
+
+func init() {
+	flags := decryptShareCmd.Flags()
+
+	flags.StringVarP(&decryptShareOpts.input, "input", "i", "", "Sealed blob to decrypt a share of")
+	fn.Must(decryptShareCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&decryptShareOpts.output, "output", "o", "", "Output file for the share")
+	fn.Must(decryptShareCmd.MarkFlagRequired("output"))
+
+	rootCmd.AddCommand(decryptShareCmd)
+}
+
+func decryptSharePreRun(_ *cobra.Command, _ []string) error {
+	err := rootOpts.Sandbox.AddReadOnlyPath(decryptShareOpts.input)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(decryptShareOpts.output)
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func decryptShareRun(cmd *cobra.Command, _ []string) (err error) {
+	cmd.SilenceUsage = true
+
+	keys, err := readBlobKeyring()
+	if err != nil {
+		return err
+	}
+
+	revocations, err := readRevocations(keys)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(decryptShareOpts.input)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(f.Close, &err)
+
+	share, err := bambi.DecryptShare(cmd.Context(), f, &bambi.Options{
+		Keyring:     keys,
+		Encrypted:   true,
+		Revocations: revocations,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = share.Write(decryptShareOpts.output)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("%d of %d shares are required to unseal %s", share.Threshold, share.Count,
+		decryptShareOpts.input)
+	log.Infof("successfully wrote decryption share to %s", decryptShareOpts.output)
+	return nil
+}
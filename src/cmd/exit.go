@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+
+	"github.com/illikainen/bambi/src/bambi"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-cryptor/src/hasher"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/process"
+	"github.com/illikainen/go-utils/src/sandbox"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Exit statuses for failed commands.  They're stable so that scripts can
+// tell the reason for a failure apart without parsing the error message.
+// Blobs that are signed by a key that isn't in the keyring can't be told
+// apart from blobs with an invalid signature, and truncated blobs are
+// invalid too.  ExitIO is only used if the blob can't be opened, read or
+// downloaded.
+const (
+	ExitFailure         = 1
+	ExitUsage           = 2
+	ExitBadSignature    = 3
+	ExitUntrustedSigner = 4
+	ExitIO              = 5
+)
+
+// The sandboxed process reports its exit status to the parent process as
+// a field in its fatal message, because the parent only gets the message.
+const exitField = "exit"
+
+var sandboxStatus struct {
+	started bool
+	code    int
+}
+
+// Fatal logs err and exits with the status for err.
+func Fatal(cmd *cobra.Command, err error) {
+	code := exitCode(cmd, err)
+
+	entry := log.NewEntry(log.StandardLogger())
+	if sandbox.IsSandboxed() {
+		entry = entry.WithField(exitField, code)
+	}
+	entry.Logf(log.FatalLevel, "%s", err)
+
+	os.Exit(code) // revive:disable-line
+}
+
+// exitCode returns the exit status for err.  Errors from before the
+// command starts running are usage errors unless they have a more
+// specific reason.
+func exitCode(cmd *cobra.Command, err error) int {
+	if sandboxStatus.started {
+		if sandboxStatus.code != 0 {
+			return sandboxStatus.code
+		}
+		return ExitFailure
+	}
+
+	var pathErr *fs.PathError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, cryptor.ErrInvalidSignature), errors.Is(err, hasher.ErrInvalidHash),
+		errors.Is(err, iofs.ErrInvalidSize), errors.Is(err, cryptor.ErrDecrypt):
+		return ExitBadSignature
+	case errors.Is(err, bambi.ErrUntrustedSigner):
+		return ExitUntrustedSigner
+	case errors.As(err, &pathErr), errors.As(err, &urlErr):
+		return ExitIO
+	case cmd != nil && !cmd.SilenceUsage:
+		return ExitUsage
+	}
+	return ExitFailure
+}
+
+// exitSandbox records that the command is run in a sandboxed process so
+// that the exit status of that process is used.
+type exitSandbox struct {
+	sandbox.Sandbox
+}
+
+func (s *exitSandbox) Confine() error {
+	sandboxStatus.started = !sandbox.IsSandboxed()
+	return s.Sandbox.Confine()
+}
+
+// sandboxStderr logs the output of the sandboxed process and records the
+// exit status in its fatal message.
+func sandboxStderr(reader io.Reader, src int, trusted bool) ([]byte, error) {
+	return process.LogrusOutput(io.TeeReader(reader, &exitRecorder{}), src, trusted)
+}
+
+type exitRecorder struct {
+	buf []byte
+}
+
+func (r *exitRecorder) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	for {
+		idx := bytes.IndexByte(r.buf, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+
+		var fields struct {
+			Level string `json:"level"`
+			Exit  int    `json:"exit"`
+		}
+		if json.Unmarshal(r.buf[:idx], &fields) == nil && fields.Level == log.FatalLevel.String() {
+			sandboxStatus.code = fields.Exit
+		}
+		r.buf = r.buf[idx+1:]
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/illikainen/bambi/src/keyformat"
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var exportKeyOpts struct {
+	input      string
+	output     string
+	format     string
+	comment    string
+	private    bool
+	passphrase bool
+	oldPass    []byte
+	newPass    []byte
+}
+
+var exportKeyCmd = &cobra.Command{
+	Use:   "export-key",
+	Short: "Export the signing key in another format",
+	Long: "Export the signing key in another format.\n\n" +
+		"Only the Ed25519 signing key is exported.  It can be used with other\n" +
+		"tools, e.g. for SSH commit signing in git, but signatures made with it\n" +
+		"can't be verified by bambi.  Exported minisign and signify private keys\n" +
+		"are unencrypted.\n",
+	PreRunE: exportKeyPreRun,
+	RunE:    exportKeyRun,
+}
+
+func init() {
+	flags := exportKeyCmd.Flags()
+
+	flags.StringVarP(&exportKeyOpts.input, "input", "i", "", "Key to export")
+	fn.Must(exportKeyCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&exportKeyOpts.output, "output", "o", "", "Output file for the exported key")
+	fn.Must(exportKeyCmd.MarkFlagRequired("output"))
+
+	flags.StringVarP(&exportKeyOpts.format, "format", "f", keyformat.OpenSSH,
+		"Format of the exported key ("+strings.Join(keyformat.Formats, ", ")+")")
+	flags.StringVarP(&exportKeyOpts.comment, "comment", "c", "", "Comment for the exported key")
+	flags.BoolVarP(&exportKeyOpts.private, "private", "P", false, "Treat the key as a private key")
+	flags.BoolVarP(&exportKeyOpts.passphrase, "passphrase", "", false,
+		"Protect the exported private key with a passphrase (OpenSSH only)")
+
+	rootCmd.AddCommand(exportKeyCmd)
+}
+
+func exportKeyPreRun(_ *cobra.Command, _ []string) (err error) {
+	if exportKeyOpts.passphrase && !exportKeyOpts.private {
+		return errors.Errorf("only private keys can be protected with a passphrase")
+	}
+
+	if exportKeyOpts.private {
+		exportKeyOpts.oldPass, err = readKeyPassphrase(exportKeyOpts.input)
+		if err != nil {
+			return err
+		}
+	}
+
+	if exportKeyOpts.passphrase {
+		exportKeyOpts.newPass, err = readNewPassphrase("Passphrase for the exported key")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(exportKeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(exportKeyOpts.output)
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func exportKeyRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	comment := exportKeyOpts.comment
+	if comment == "" {
+		base := filepath.Base(exportKeyOpts.input)
+		comment = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	var data []byte
+	if exportKeyOpts.private {
+		key, err := keyring.ReadPrivateKey(exportKeyOpts.input, exportKeyOpts.oldPass)
+		if err != nil {
+			return err
+		}
+
+		data, err = keyformat.ExportPrivateKey(key, exportKeyOpts.format, comment, exportKeyOpts.newPass)
+		if err != nil {
+			return err
+		}
+	} else {
+		key, err := asymmetric.ReadPublicKey(exportKeyOpts.input)
+		if err != nil {
+			return err
+		}
+
+		data, err = keyformat.ExportPublicKey(key, exportKeyOpts.format, comment)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := os.WriteFile(exportKeyOpts.output, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("successfully exported %s to %s", exportKeyOpts.input, exportKeyOpts.output)
+	return nil
+}
@@ -1,6 +1,11 @@
 package cmd
 
 import (
+	"strings"
+
+	"github.com/illikainen/bambi/src/fingerprint"
+	"github.com/illikainen/bambi/src/keyring"
+
 	"github.com/illikainen/go-cryptor/src/asymmetric"
 	"github.com/illikainen/go-utils/src/fn"
 	log "github.com/sirupsen/logrus"
@@ -8,8 +13,10 @@ import (
 )
 
 var fingerprintOpts struct {
-	input   string
-	private bool
+	input      string
+	private    bool
+	format     string
+	passphrase []byte
 }
 
 var fingerprintCmd = &cobra.Command{
@@ -26,6 +33,8 @@ func init() {
 	fn.Must(fingerprintCmd.MarkFlagRequired("input"))
 
 	flags.BoolVarP(&fingerprintOpts.private, "private", "P", false, "Treat the key as a private key")
+	flags.StringVarP(&fingerprintOpts.format, "format", "f", "base64",
+		"Fingerprint format ("+strings.Join(fingerprint.Formats, ", ")+")")
 
 	rootCmd.AddCommand(fingerprintCmd)
 }
@@ -36,29 +45,49 @@ func fingerprintPreRun(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
+	if fingerprintOpts.private {
+		fingerprintOpts.passphrase, err = readKeyPassphrase(fingerprintOpts.input)
+		if err != nil {
+			return err
+		}
+	}
+
 	return rootOpts.Sandbox.Confine()
 }
 
 func fingerprintRun(cmd *cobra.Command, _ []string) error {
 	cmd.SilenceUsage = true
 
-	fingerprint := ""
+	fpr := ""
 	if fingerprintOpts.private {
-		key, err := asymmetric.ReadPrivateKey(fingerprintOpts.input)
+		key, err := keyring.ReadPrivateKey(fingerprintOpts.input, fingerprintOpts.passphrase)
 		if err != nil {
 			return err
 		}
 
-		fingerprint = key.Fingerprint()
+		fpr = key.Fingerprint()
 	} else {
 		key, err := asymmetric.ReadPublicKey(fingerprintOpts.input)
 		if err != nil {
 			return err
 		}
 
-		fingerprint = key.Fingerprint()
+		fpr = key.Fingerprint()
 	}
 
-	log.Infof("fingerprint for %s is %s", fingerprintOpts.input, fingerprint)
+	lines, err := fingerprint.Format(fpr, fingerprintOpts.format)
+	if err != nil {
+		return err
+	}
+
+	if len(lines) == 1 {
+		log.Infof("fingerprint for %s is %s", fingerprintOpts.input, lines[0])
+		return nil
+	}
+
+	log.Infof("fingerprint for %s:", fingerprintOpts.input)
+	for _, line := range lines {
+		log.Infof("%s", line)
+	}
 	return nil
 }
@@ -2,17 +2,32 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/illikainen/bambi/src/keyring"
+	"github.com/illikainen/bambi/src/mnemonic"
+
 	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/cryptor"
 	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var genkeyOpts struct {
-	output string
-	delay  time.Duration
+	output     string
+	delay      time.Duration
+	passphrase bool
+	name       string
+	comment    string
+	usage      string
+	mnemonic   bool
+	primary    string
+	subkey     string
+	expires    time.Duration
 }
 
 var genkeyCmd = &cobra.Command{
@@ -31,27 +46,192 @@ func init() {
 	flags.DurationVarP(&genkeyOpts.delay, "delay", "d", 60*time.Second,
 		"Add a delay between each generated key")
 
+	flags.BoolVarP(&genkeyOpts.passphrase, "passphrase", "", false,
+		"Protect the private key with a passphrase")
+
+	flags.StringVarP(&genkeyOpts.name, "name", "", "", "Name of the key owner")
+	flags.StringVarP(&genkeyOpts.comment, "comment", "c", "", "Comment for the key")
+	flags.StringVarP(&genkeyOpts.usage, "usage", "u", "", "Intended use of the key")
+	flags.BoolVarP(&genkeyOpts.mnemonic, "mnemonic", "m", false,
+		"Derive the keypair from recovery words that are shown once (see restore-key)")
+
+	flags.StringVarP(&genkeyOpts.primary, "primary", "", "",
+		"Certify the keypair as a subkey of this private key")
+	flags.StringVarP(&genkeyOpts.subkey, "subkey", "", "",
+		"Purpose of the subkey ("+strings.Join(keyring.Usages, " or ")+")")
+	flags.DurationVarP(&genkeyOpts.expires, "expires", "", 0,
+		"Refuse to use the subkey after this duration (e.g. 2160h)")
+
 	rootCmd.AddCommand(genkeyCmd)
 }
 
 func genkeyRun(_ *cobra.Command, _ []string) error {
-	pubKey, privKey, err := asymmetric.GenerateKey(genkeyOpts.delay)
+	if (genkeyOpts.primary == "") != (genkeyOpts.subkey == "") {
+		return errors.Errorf("--primary and --subkey must be specified together")
+	}
+
+	if genkeyOpts.expires != 0 && genkeyOpts.primary == "" {
+		return errors.Errorf("only subkeys can expire")
+	}
+
+	if genkeyOpts.subkey != "" {
+		err := keyring.ValidateUsage(genkeyOpts.subkey)
+		if err != nil {
+			return err
+		}
+	}
+
+	primary, err := readPrimaryKey(genkeyOpts.primary)
+	if err != nil {
+		return err
+	}
+
+	var passphrase []byte
+	if genkeyOpts.passphrase {
+		var err error
+		passphrase, err = readNewPassphrase("Passphrase")
+		if err != nil {
+			return err
+		}
+
+		if len(passphrase) == 0 {
+			return errors.Errorf("the passphrase can't be empty")
+		}
+	}
+
+	meta := keyring.NewMetadata(genkeyOpts.name, genkeyOpts.comment, genkeyOpts.usage)
+	err = meta.Validate()
 	if err != nil {
 		return err
 	}
 
-	pubFile := fmt.Sprintf("%s.pub", genkeyOpts.output)
-	err = pubKey.Write(pubFile)
+	words := ""
+	var pubKey cryptor.PublicKey
+	var privKey cryptor.PrivateKey
+	if genkeyOpts.mnemonic {
+		words, err = mnemonic.Generate()
+		if err != nil {
+			return err
+		}
+
+		seed, err := mnemonic.Seed(words)
+		if err != nil {
+			return err
+		}
+
+		pubKey, privKey, err = keyring.DeriveKey(seed)
+		if err != nil {
+			return err
+		}
+	} else {
+		pubKey, privKey, err = asymmetric.GenerateKey(genkeyOpts.delay)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeKeypair(genkeyOpts.output, pubKey, privKey, passphrase, meta)
 	if err != nil {
 		return err
 	}
 
-	privFile := fmt.Sprintf("%s.priv", genkeyOpts.output)
-	err = privKey.Write(privFile)
+	if primary != nil {
+		err = certifyKeypair(genkeyOpts.output, primary, pubKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if words != "" {
+		return printMnemonic(words)
+	}
+	return nil
+}
+
+// writeKeypair writes the keypair to <output>.pub and <output>.priv.
+func writeKeypair(output string, pubKey cryptor.PublicKey, privKey cryptor.PrivateKey, passphrase []byte,
+	meta *keyring.Metadata) error {
+	pubFile := fmt.Sprintf("%s.pub", output)
+	err := pubKey.Write(pubFile)
 	if err != nil {
 		return err
 	}
 
+	privFile := fmt.Sprintf("%s.priv", output)
+	err = keyring.WritePrivateKey(privFile, privKey, passphrase)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{pubFile, privFile} {
+		err = keyring.WriteMetadata(path, meta)
+		if err != nil {
+			return err
+		}
+	}
+
 	log.Infof("successfully wrote %s to %s and %s", pubKey, pubFile, privFile)
 	return nil
 }
+
+// readPrimaryKey returns the private key in path, or nil if path is empty.
+func readPrimaryKey(path string) (cryptor.PrivateKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	_, cert, err := keyring.ReadCertificate(path)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		return nil, errors.Errorf("%s: subkeys can't certify other keys", path)
+	}
+
+	passphrase, err := readKeyPassphrase(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyring.ReadPrivateKey(path, passphrase)
+}
+
+// certifyKeypair stores a certificate by primary in both files of the
+// keypair in <output>.pub and <output>.priv.
+func certifyKeypair(output string, primary cryptor.PrivateKey, pubKey cryptor.PublicKey) error {
+	env, err := keyring.Certify(primary, pubKey, genkeyOpts.subkey, genkeyOpts.expires)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{output + ".pub", output + ".priv"} {
+		err = keyring.WriteCertificate(path, env)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("successfully certified %s as a subkey of %s (usage: %s)", pubKey, primary, genkeyOpts.subkey)
+	return nil
+}
+
+// printMnemonic writes the recovery words to stdout in numbered rows so
+// that they're easy to copy to paper.  They aren't logged because they
+// would be hidden by a log level above info.
+func printMnemonic(words string) error {
+	log.Warnf("write down the recovery words; they can restore the private key and won't be shown again")
+
+	fields := strings.Fields(words)
+	for i := 0; i < len(fields); i += 6 {
+		row := []string{}
+		for j := i; j < i+6 && j < len(fields); j++ {
+			row = append(row, fmt.Sprintf("%2d. %-8s", j+1, fields[j]))
+		}
+
+		_, err := fmt.Fprintf(os.Stdout, "%s\n", strings.TrimSpace(strings.Join(row, " ")))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,11 +2,12 @@ package cmd
 
 import (
 	"net/url"
-	"os"
+	"path/filepath"
 
-	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/atomicfile"
+	"github.com/illikainen/bambi/src/auditlog"
+	"github.com/illikainen/bambi/src/bambi"
 
-	"github.com/illikainen/go-cryptor/src/blob"
 	"github.com/illikainen/go-netutils/src/sshx"
 	"github.com/illikainen/go-utils/src/errorx"
 	"github.com/illikainen/go-utils/src/fn"
@@ -15,9 +16,13 @@ import (
 )
 
 var getOpts struct {
-	url        *url.URL
-	output     string
-	signedOnly bool
+	url          *url.URL
+	output       string
+	signedOnly   bool
+	allowExpired bool
+	words        bool
+	format       string
+	policyOptions
 }
 
 var getCmd = &cobra.Command{
@@ -37,15 +42,35 @@ func init() {
 	flags.BoolVarP(&getOpts.signedOnly, "signed-only", "s", false,
 		"Required if the archive is signed but not encrypted")
 
+	flags.BoolVarP(&getOpts.allowExpired, "allow-expired", "", false, "Accept blobs that have expired")
+
+	flags.BoolVarP(&getOpts.words, "words", "", false,
+		"Also show the fingerprints of the signers as words")
+
+	addFormatFlag(getCmd, &getOpts.format)
+
+	flags.StringArrayVarP(&getOpts.signers, "signer", "", nil,
+		"Only accept blobs signed by the key or group with this fingerprint or name (may be repeated)")
+
+	flags.IntVarP(&getOpts.requireSigners, "require-signers", "", 0,
+		"Require signatures by at least this many keys, including cosigners")
+	flags.StringVarP(&getOpts.policy, "policy", "", "", "Signature policy from the config to enforce")
+
 	rootCmd.AddCommand(getCmd)
 }
 
 func getPreRun(_ *cobra.Command, args []string) error {
+	err := setupFormat(getOpts.format)
+	if err != nil {
+		return err
+	}
+
 	ro, rw, err := sshx.SandboxPaths()
 	if err != nil {
 		return err
 	}
-	rw = append(rw, getOpts.output)
+	// The directory is needed for the temporary file and the lock.
+	rw = append(rw, getOpts.output, getOpts.output+bambi.CosignExt, filepath.Dir(getOpts.output))
 
 	uri, err := url.Parse(args[0])
 	if err != nil {
@@ -69,36 +94,93 @@ func getPreRun(_ *cobra.Command, args []string) error {
 		return err
 	}
 
+	err = addAuditLogPaths()
+	if err != nil {
+		return err
+	}
+
+	err = removeOnInterrupt(atomicfile.PartialPath(getOpts.output))
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
 	return rootOpts.Sandbox.Confine()
 }
 
 func getRun(cmd *cobra.Command, _ []string) (err error) {
 	cmd.SilenceUsage = true
+	defer cleanupOnInterrupt(cmd.Context(), &err)
+
+	rep := newReport()
+	defer writeReport(getOpts.format, rep, &err)
+	defer recordReport(&auditlog.Entry{Command: "get", Path: getOpts.output, URL: getOpts.url.String()},
+		rep, &err)
 
-	keys, err := blob.ReadKeyring(rootOpts.PrivKey, rootOpts.PubKeys)
+	keys, err := readKeyring()
 	if err != nil {
 		return err
 	}
 
-	f, err := os.OpenFile(getOpts.output, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600) // #nosec G304
+	policy, err := signaturePolicy(keys, &getOpts.policyOptions)
+	if err != nil {
+		return err
+	}
+
+	revocations, err := readRevocations(keys.Blob())
+	if err != nil {
+		return err
+	}
+
+	cosig, err := bambi.FetchCosignatures(cmd.Context(), getOpts.url)
+	if err != nil {
+		return err
+	}
+
+	f, err := atomicfile.Create(getOpts.output)
 	if err != nil {
 		return err
 	}
 	defer errorx.Defer(f.Close, &err)
 
-	blobber, err := blob.Download(getOpts.url, f, &blob.Options{
-		Type:      metadata.Name(),
-		Keyring:   keys,
-		Encrypted: !getOpts.signedOnly,
+	// The existing output is used as a cache by the download.
+	err = f.CopyExisting()
+	if err != nil {
+		return err
+	}
+
+	res, err := bambi.Get(cmd.Context(), getOpts.url, f, &bambi.Options{
+		Keyring:        keys.Blob(),
+		Encrypted:      !getOpts.signedOnly,
+		AllowExpired:   getOpts.allowExpired,
+		Revocations:    revocations,
+		Cosignatures:   cosig,
+		Policy:         policy,
+		TrustedSigners: trustedSigners(keys, &getOpts.policyOptions),
 	})
 	if err != nil {
+		rep.setError(err)
 		return err
 	}
 
-	log.Infof("signed by: %s", blobber.Signer)
-	log.Infof("sha2-256: %s", blobber.Metadata.Hashes.SHA256)
-	log.Infof("sha3-512: %s", blobber.Metadata.Hashes.KECCAK512)
-	log.Infof("blake2b-512: %s", blobber.Metadata.Hashes.BLAKE2b512)
+	err = f.Commit()
+	if err != nil {
+		return err
+	}
+
+	if cosig != nil {
+		err = cosig.Write(getOpts.output + bambi.CosignExt)
+		if err != nil {
+			return err
+		}
+	}
+
+	rep.setResult(res)
+	logResult(keys, res, getOpts.words)
 	log.Infof("successfully wrote sealed blob from %s to %s", getOpts.url, getOpts.output)
 	return nil
 }
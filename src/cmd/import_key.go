@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/illikainen/bambi/src/keyformat"
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var importKeyOpts struct {
+	input      string
+	output     string
+	format     string
+	delay      time.Duration
+	passphrase bool
+	oldPass    []byte
+	newPass    []byte
+}
+
+var importKeyCmd = &cobra.Command{
+	Use:   "import-key",
+	Short: "Create a keypair from a signing key in another format",
+	Long: "Create a keypair from a signing key in another format.\n\n" +
+		"The imported Ed25519 key is used as the signing key of a new keypair.\n" +
+		"The remaining keys are generated because other formats only have an\n" +
+		"Ed25519 key.  Only OpenSSH private keys can be imported.\n",
+	PreRunE: importKeyPreRun,
+	RunE:    importKeyRun,
+}
+
+func init() {
+	flags := importKeyCmd.Flags()
+
+	flags.StringVarP(&importKeyOpts.input, "input", "i", "", "Private key to import")
+	fn.Must(importKeyCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&importKeyOpts.output, "output", "o", "",
+		"Write the keypair to <output>.pub and <output>.priv")
+	fn.Must(importKeyCmd.MarkFlagRequired("output"))
+
+	flags.StringVarP(&importKeyOpts.format, "format", "f", keyformat.OpenSSH,
+		"Format of the imported key ("+keyformat.OpenSSH+")")
+	flags.DurationVarP(&importKeyOpts.delay, "delay", "d", 60*time.Second,
+		"Add a delay between each generated key")
+	flags.BoolVarP(&importKeyOpts.passphrase, "passphrase", "", false,
+		"Protect the private key with a passphrase")
+
+	rootCmd.AddCommand(importKeyCmd)
+}
+
+func importKeyPreRun(_ *cobra.Command, _ []string) error {
+	data, err := iofs.ReadFile(importKeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	if keyformat.IsEncrypted(data) {
+		importKeyOpts.oldPass, err = readPassphrase(fmt.Sprintf("Passphrase for %s", importKeyOpts.input))
+		if err != nil {
+			return err
+		}
+	}
+
+	if importKeyOpts.passphrase {
+		importKeyOpts.newPass, err = readNewPassphrase("Passphrase")
+		if err != nil {
+			return err
+		}
+
+		if len(importKeyOpts.newPass) == 0 {
+			return errors.Errorf("the passphrase can't be empty")
+		}
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(importKeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(importKeyOpts.output+".pub", importKeyOpts.output+".priv")
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func importKeyRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := iofs.ReadFile(importKeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	format := strings.ToLower(importKeyOpts.format)
+	pubKey, privKey, err := keyformat.ImportPrivateKey(data, format, importKeyOpts.oldPass, importKeyOpts.delay)
+	if err != nil {
+		return err
+	}
+
+	pubFile := fmt.Sprintf("%s.pub", importKeyOpts.output)
+	err = pubKey.Write(pubFile)
+	if err != nil {
+		return err
+	}
+
+	privFile := fmt.Sprintf("%s.priv", importKeyOpts.output)
+	err = keyring.WritePrivateKey(privFile, privKey, importKeyOpts.newPass)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("successfully wrote %s to %s and %s", pubKey, pubFile, privFile)
+	return nil
+}
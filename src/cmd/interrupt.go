@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExitInterrupted is the exit status if a command is interrupted by
+// SIGINT or SIGTERM.
+const ExitInterrupted = 130
+
+var partialOutputs struct {
+	sync.Mutex
+	paths []string
+}
+
+// removeOnInterrupt registers outputs that are removed if the command is
+// interrupted.  Paths that exist before the command is run are left as-is.
+func removeOnInterrupt(paths ...string) error {
+	partialOutputs.Lock()
+	defer partialOutputs.Unlock()
+
+	for _, path := range paths {
+		exists, err := iofs.Exists(path)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			partialOutputs.paths = append(partialOutputs.paths, path)
+		}
+	}
+
+	return nil
+}
+
+func removePartialOutputs() error {
+	partialOutputs.Lock()
+	defer partialOutputs.Unlock()
+
+	var errs []error
+	for _, path := range partialOutputs.paths {
+		log.Infof("removing partial output '%s'", path)
+		errs = append(errs, iofs.Remove(path))
+	}
+	partialOutputs.paths = nil
+
+	return errorx.Join(errs...)
+}
+
+// cleanupOnInterrupt is deferred by commands that write outputs.
+func cleanupOnInterrupt(ctx context.Context, err *error) {
+	if *err != nil && ctx.Err() != nil {
+		*err = errorx.Join(*err, removePartialOutputs())
+	}
+}
+
+// awaitInterrupt is used by the parent of a sandboxed process.  The
+// sandboxed process runs in a separate session, so it doesn't receive
+// signals from the terminal, and it's killed when the parent exits.  The
+// parent is therefore responsible for removing partial outputs.
+func awaitInterrupt(ctx context.Context) {
+	<-ctx.Done()
+
+	err := removePartialOutputs()
+	if err != nil {
+		log.Errorf("%s", err)
+	}
+
+	log.Errorf("%s", ctx.Err())
+	os.Exit(ExitInterrupted) // revive:disable-line
+}
@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/illikainen/bambi/src/config"
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage keys",
+}
+
+var keysListOpts struct {
+	paths   []string
+	sources map[string][]string
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the keys in the config, the profiles and the key directory",
+	Long: "List the keys in the config, the profiles and the key directory.\n\n" +
+		"Keys that can't be read, keys in the legacy format and duplicate keys\n" +
+		"are reported as warnings.\n",
+	PreRunE: keysListPreRun,
+	RunE:    keysListRun,
+}
+
+var keysAnnotateOpts struct {
+	input   string
+	name    string
+	comment string
+	usage   string
+	clear   bool
+}
+
+var keysAnnotateCmd = &cobra.Command{
+	Use:   "annotate",
+	Short: "Set the metadata of a key",
+	Long: "Set the metadata of a key.\n\n" +
+		"The metadata is informational only.  It isn't authenticated and it\n" +
+		"doesn't affect how the key is used.\n",
+	PreRunE: keysAnnotatePreRun,
+	RunE:    keysAnnotateRun,
+}
+
+func init() {
+	flags := keysAnnotateCmd.Flags()
+
+	flags.StringVarP(&keysAnnotateOpts.input, "input", "i", "", "Key to annotate")
+	fn.Must(keysAnnotateCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&keysAnnotateOpts.name, "name", "", "", "Name of the key owner")
+	flags.StringVarP(&keysAnnotateOpts.comment, "comment", "c", "", "Comment for the key")
+	flags.StringVarP(&keysAnnotateOpts.usage, "usage", "u", "", "Intended use of the key")
+	flags.BoolVarP(&keysAnnotateOpts.clear, "clear", "", false, "Remove all metadata from the key")
+
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysAnnotateCmd)
+	rootCmd.AddCommand(keysCmd)
+}
+
+func keysListPreRun(_ *cobra.Command, _ []string) error {
+	// The merged config only has the keys of the active profile, so the
+	// config is read again for the keys of every profile.
+	cfg, err := config.Read(rootOpts.config, &config.Config{})
+	if err != nil {
+		return err
+	}
+
+	keysListOpts.sources = map[string][]string{}
+	err = addKeySources(cfg, "", false)
+	if err != nil {
+		return err
+	}
+
+	profiles := []string{}
+	for name := range cfg.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+
+	for _, name := range profiles {
+		profile := cfg.Profiles[name]
+		err = addKeySources(&profile, "profile."+name+".", false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keys from the command line.
+	err = addKeySources(&rootOpts.Config, "", true)
+	if err != nil {
+		return err
+	}
+
+	dir, err := keyDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			err = addKeySource(filepath.Join(dir, entry.Name()), "keydir", false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(append(keysListOpts.paths, dir)...)
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func keysListRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	infos := []*keyring.Info{}
+	for _, path := range keysListOpts.paths {
+		log.Infof("%s", path)
+		log.Infof("  sources: %s", strings.Join(keysListOpts.sources[path], ", "))
+
+		info, err := keyring.Inspect(path)
+		if err != nil {
+			log.Warnf("  unreadable: %s", err)
+			continue
+		}
+		infos = append(infos, info)
+
+		logKeyInfo(info)
+		if info.Legacy {
+			log.Warnf("  legacy format (use convert-key to convert it)")
+		}
+
+		for _, other := range infos {
+			if other != info && other.Fingerprint != "" && other.Fingerprint == info.Fingerprint &&
+				other.Private == info.Private {
+				log.Warnf("  duplicate of %s", other.Path)
+			}
+		}
+	}
+
+	log.Infof("checked %d key file(s)", len(keysListOpts.paths))
+	return nil
+}
+
+func keysAnnotatePreRun(_ *cobra.Command, _ []string) error {
+	if keysAnnotateOpts.clear && (keysAnnotateOpts.name != "" || keysAnnotateOpts.comment != "" ||
+		keysAnnotateOpts.usage != "") {
+		return errors.Errorf("--clear can't be combined with other metadata")
+	}
+
+	err := rootOpts.Sandbox.AddReadWritePath(keysAnnotateOpts.input)
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func keysAnnotateRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	info, err := keyring.Inspect(keysAnnotateOpts.input)
+	if err != nil {
+		return err
+	}
+
+	if info.Legacy {
+		return errors.Errorf("%s: legacy keys can't be annotated (use convert-key to convert it)",
+			keysAnnotateOpts.input)
+	}
+
+	meta := &keyring.Metadata{}
+	if !keysAnnotateOpts.clear {
+		meta = info.Metadata
+		if keysAnnotateOpts.name != "" {
+			meta.Name = keysAnnotateOpts.name
+		}
+		if keysAnnotateOpts.comment != "" {
+			meta.Comment = keysAnnotateOpts.comment
+		}
+		if keysAnnotateOpts.usage != "" {
+			meta.Usage = keysAnnotateOpts.usage
+		}
+	}
+
+	err = keyring.WriteMetadata(info.Path, meta)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("successfully annotated %s", info.Path)
+	return nil
+}
+
+func logKeyInfo(info *keyring.Info) {
+	kind := fn.Ternary(info.Private, "private", "public")
+	if info.Encrypted {
+		kind += " (encrypted)"
+	}
+	log.Infof("  type: %s", kind)
+
+	if info.Fingerprint != "" {
+		log.Infof("  fingerprint: %s", info.Fingerprint)
+	}
+
+	if cert := info.Certificate; cert != nil {
+		log.Infof("  %s of: %s", cert.Describe(), cert.Primary)
+		if !cert.ExpiresTime().IsZero() {
+			log.Infof("  expires: %s", cert.ExpiresTime().UTC().Format(time.RFC3339))
+		}
+	}
+
+	meta := info.Metadata
+	if meta == nil {
+		return
+	}
+	if meta.Name != "" {
+		log.Infof("  name: %s", meta.Name)
+	}
+	if meta.Comment != "" {
+		log.Infof("  comment: %s", meta.Comment)
+	}
+	if !meta.CreatedTime().IsZero() {
+		log.Infof("  created: %s", meta.CreatedTime().UTC().Format(time.RFC3339))
+	}
+	if meta.Usage != "" {
+		log.Infof("  usage: %s", meta.Usage)
+	}
+}
+
+func addKeySources(cfg *config.Config, prefix string, unseen bool) error {
+	if cfg.PrivKey != "" {
+		err := addKeySource(cfg.PrivKey, prefix+"privkey", unseen)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range cfg.PubKeys {
+		err := addKeySource(path, prefix+"pubkeys", unseen)
+		if err != nil {
+			return err
+		}
+	}
+
+	names := []string{}
+	for name := range cfg.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := addKeySource(cfg.Keys[name], prefix+"keys."+name, unseen)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addKeySource records that the key in path is referenced by source.  If
+// unseen is true, the source is only recorded for new paths.
+func addKeySource(path string, source string, unseen bool) error {
+	path, err := iofs.Expand(path)
+	if err != nil {
+		return err
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	sources, ok := keysListOpts.sources[path]
+	if ok && unseen {
+		return nil
+	}
+	if !ok {
+		keysListOpts.paths = append(keysListOpts.paths, path)
+	}
+
+	for _, elt := range sources {
+		if elt == source {
+			return nil
+		}
+	}
+	keysListOpts.sources[path] = append(sources, source)
+	return nil
+}
+
+func keyDir() (string, error) {
+	if rootOpts.KeyDir != "" {
+		return rootOpts.KeyDir, nil
+	}
+	return config.KeyDir()
+}
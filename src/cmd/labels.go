@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/illikainen/bambi/src/archive"
+
+	"github.com/pkg/errors"
+)
+
+func parseLabels(values []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, value := range values {
+		key, value, err := archive.ParseLabel(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := labels[key]; ok {
+			return nil, errors.Errorf("%s: duplicate label", key)
+		}
+		labels[key] = value
+	}
+
+	return labels, nil
+}
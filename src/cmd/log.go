@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/illikainen/bambi/src/auditlog"
+	"github.com/illikainen/bambi/src/config"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/sandbox"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Manage the audit log",
+	Long: "Manage the audit log.\n\n" +
+		"If `auditlog` is set in the configuration, seal, verify, unseal, get and\n" +
+		"put append an entry to the log for every blob that they handle.  The\n" +
+		"entries are hash-chained and the number of entries and the hash of the\n" +
+		"last entry are kept in <auditlog>.head.\n\n" +
+		"The entries and the head are authenticated with a secret key in\n" +
+		"`auditlogkey` (default: auditlog.key in the configuration directory),\n" +
+		"which is created the first time the log is written.  The directory of\n" +
+		"the log must be writable in the sandbox, so the key can't be in it.\n" +
+		"Anyone who can read the key can forge the log.\n",
+}
+
+var logVerifyOpts struct {
+	input string
+}
+
+var logVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify that the audit log hasn't been edited or truncated",
+	Long: "Verify that the audit log hasn't been edited or truncated.\n\n" +
+		"The configured audit log is verified unless --input is specified.  An\n" +
+		"entry can't be edited, removed or added, and the log can't be truncated\n" +
+		"or replaced, without the key for the log.  A log that was removed with\n" +
+		"its head can't be told apart from one that was never written, so it's\n" +
+		"an error if the log has no entries.  The log can still be rolled back\n" +
+		"to an earlier state by anyone who kept a copy of its head.\n",
+	PreRunE: logVerifyPreRun,
+	RunE:    logVerifyRun,
+}
+
+func init() {
+	flags := logVerifyCmd.Flags()
+
+	flags.StringVarP(&logVerifyOpts.input, "input", "i", "", "Audit log to verify")
+
+	logCmd.AddCommand(logVerifyCmd)
+	rootCmd.AddCommand(logCmd)
+}
+
+func logVerifyPreRun(_ *cobra.Command, _ []string) (err error) {
+	if logVerifyOpts.input == "" {
+		logVerifyOpts.input = rootOpts.AuditLog
+	}
+
+	if logVerifyOpts.input == "" {
+		return errors.Errorf("an audit log must be configured or specified with --input")
+	}
+
+	logVerifyOpts.input, err = iofs.Expand(logVerifyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	rootOpts.AuditLogKey, err = auditLogKey()
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(logVerifyOpts.input, auditlog.HeadPath(logVerifyOpts.input),
+		rootOpts.AuditLogKey)
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func logVerifyRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	key, err := auditlog.ReadKey(rootOpts.AuditLogKey)
+	if err != nil {
+		return err
+	}
+
+	l, err := auditlog.Verify(logVerifyOpts.input, key)
+	if err != nil {
+		return err
+	}
+
+	if len(l.Entries) == 0 {
+		return errors.Errorf("%s: the log has no entries", logVerifyOpts.input)
+	}
+
+	if extra := len(l.Entries) - l.Head.Count; extra > 0 {
+		log.Warnf("%d entries at the end of the log aren't included in the head", extra)
+	}
+
+	first := time.Unix(l.Entries[0].Time, 0).UTC().Format(time.RFC3339)
+	last := time.Unix(l.Entries[len(l.Entries)-1].Time, 0).UTC().Format(time.RFC3339)
+	log.Infof("entries: %d (%s to %s)", len(l.Entries), first, last)
+	log.Infof("head: %d %s", l.Head.Count, l.Head.Hash)
+	log.Infof("successfully verified %s", logVerifyOpts.input)
+	return nil
+}
+
+// addAuditLogPaths makes the configured audit log writable in the sandbox.
+// The directory is needed for the head, which is replaced atomically.  The
+// key for the log is created if it doesn't exist.
+func addAuditLogPaths() (err error) {
+	if rootOpts.AuditLog == "" {
+		return nil
+	}
+
+	rootOpts.AuditLog, err = iofs.Expand(rootOpts.AuditLog)
+	if err != nil {
+		return err
+	}
+
+	rootOpts.AuditLogKey, err = auditLogKey()
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(rootOpts.AuditLog))
+	if err != nil {
+		return err
+	}
+
+	key, err := filepath.Abs(rootOpts.AuditLogKey)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(dir, key)
+	if err != nil {
+		return err
+	}
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.Errorf("%s: the audit log key can't be in the directory of the log", key)
+	}
+
+	if !sandbox.IsSandboxed() {
+		err = auditlog.CreateKey(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(key)
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.AddReadWritePath(rootOpts.AuditLog, dir)
+}
+
+// auditLogKey returns the path of the key for the audit log.
+func auditLogKey() (string, error) {
+	if rootOpts.AuditLogKey != "" {
+		return iofs.Expand(rootOpts.AuditLogKey)
+	}
+	return config.AuditLogKey()
+}
+
+// recordAudit appends entry to the configured audit log.  It's deferred by
+// the commands that handle blobs so that failures are recorded too.
+func recordAudit(entry *auditlog.Entry, err *error) {
+	if rootOpts.AuditLog == "" {
+		return
+	}
+
+	if *err != nil {
+		entry.Error = (*err).Error()
+	}
+
+	if entry.Path != "" {
+		path, absErr := filepath.Abs(entry.Path)
+		if absErr == nil {
+			entry.Path = path
+		}
+	}
+
+	key, auditErr := auditlog.ReadKey(rootOpts.AuditLogKey)
+	if auditErr == nil {
+		auditErr = auditlog.Append(rootOpts.AuditLog, key, entry)
+	}
+	if auditErr != nil {
+		*err = errorx.Join(*err, errors.Errorf("could not write to the audit log: %s", auditErr))
+	}
+}
+
+// recordReport appends entry to the configured audit log with the signer
+// and the hashes in the report of a verifying command.
+func recordReport(entry *auditlog.Entry, rep *report, err *error) {
+	entry.Signer = rep.Signer
+	entry.Hashes = rep.Hashes
+	recordAudit(entry, err)
+}
@@ -4,9 +4,8 @@ import (
 	"encoding/json"
 	"os"
 
-	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/bambi"
 
-	"github.com/illikainen/go-cryptor/src/blob"
 	"github.com/illikainen/go-utils/src/errorx"
 	"github.com/illikainen/go-utils/src/fn"
 	"github.com/pkg/errors"
@@ -55,13 +54,23 @@ func metadataPreRun(_ *cobra.Command, _ []string) error {
 		}
 	}
 
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
 	return rootOpts.Sandbox.Confine()
 }
 
 func metadataRun(cmd *cobra.Command, _ []string) (err error) {
 	cmd.SilenceUsage = true
 
-	keys, err := blob.ReadKeyring(rootOpts.PrivKey, rootOpts.PubKeys)
+	keys, err := readBlobKeyring()
+	if err != nil {
+		return err
+	}
+
+	revocations, err := readRevocations(keys)
 	if err != nil {
 		return err
 	}
@@ -72,22 +81,25 @@ func metadataRun(cmd *cobra.Command, _ []string) (err error) {
 	}
 	defer errorx.Defer(f.Close, &err)
 
-	blobber, err := blob.NewReader(f, &blob.Options{
-		Type:      metadata.Name(),
-		Keyring:   keys,
-		Encrypted: !metadataOpts.signedOnly,
+	// The metadata of expired blobs is shown too; it's only reported.
+	res, err := bambi.Verify(cmd.Context(), f, &bambi.Options{
+		Keyring:      keys,
+		Encrypted:    !metadataOpts.signedOnly,
+		AllowExpired: true,
+		Revocations:  revocations,
 	})
 	if err != nil {
 		return err
 	}
 
-	meta, err := json.MarshalIndent(blobber.Metadata, "", "    ")
+	meta, err := json.MarshalIndent(res.Metadata, "", "    ")
 	if err != nil {
 		return err
 	}
 	meta = append(meta, '\n')
 
 	log.Infof("%s", meta)
+	logLabels(res.Labels)
 	if metadataOpts.output != "" {
 		f, err := os.Create(metadataOpts.output)
 		if err != nil {
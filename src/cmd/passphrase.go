@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/illikainen/bambi/src/agent"
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-cryptor/src/blob"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/sandbox"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// Passphrases are read before the process is confined because the
+// sandboxed process doesn't have access to the terminal.  The parent
+// process forwards them to the sandboxed process on stdin, one per line,
+// in the order that they were read.
+var passphrases struct {
+	forward bytes.Buffer
+	stdin   *bufio.Reader
+}
+
+// readPassphrase reads a passphrase from the terminal, or from stdin if it
+// isn't a terminal.
+func readPassphrase(prompt string) ([]byte, error) {
+	var passphrase []byte
+	fd := int(os.Stdin.Fd()) // #nosec G115
+
+	if !sandbox.IsSandboxed() && term.IsTerminal(fd) {
+		_, err := fmt.Fprintf(os.Stderr, "%s: ", prompt)
+		if err != nil {
+			return nil, err
+		}
+
+		passphrase, err = term.ReadPassword(fd)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if passphrases.stdin == nil {
+			passphrases.stdin = bufio.NewReader(os.Stdin)
+		}
+
+		line, err := passphrases.stdin.ReadBytes('\n')
+		if err != nil {
+			return nil, errors.Errorf("%s: %s", prompt, err)
+		}
+		passphrase = bytes.TrimRight(line, "\r\n")
+	}
+
+	if !sandbox.IsSandboxed() {
+		passphrases.forward.Write(passphrase)
+		passphrases.forward.WriteByte('\n')
+		rootOpts.Sandbox.SetStdin(bytes.NewReader(passphrases.forward.Bytes()))
+	}
+
+	return passphrase, nil
+}
+
+// readNewPassphrase reads a passphrase twice and fails unless both are
+// identical.
+func readNewPassphrase(prompt string) ([]byte, error) {
+	passphrase, err := readPassphrase(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	again, err := readPassphrase(prompt + " (again)")
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(passphrase, again) {
+		return nil, errors.Errorf("the passphrases don't match")
+	}
+
+	return passphrase, nil
+}
+
+// readKeyPassphrase reads the passphrase for the private key in path if
+// it's encrypted.
+func readKeyPassphrase(path string) ([]byte, error) {
+	path, err := iofs.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := keyring.IsEncrypted(path)
+	if err != nil || !encrypted {
+		return nil, err
+	}
+
+	return readPassphrase(fmt.Sprintf("Passphrase for %s", path))
+}
+
+// readPrivKeyPassphrase reads the passphrase for the configured private
+// key.  It must be called before the process is confined.
+func readPrivKeyPassphrase() (err error) {
+	if rootOpts.PrivKey == "" || agentSocket() != "" {
+		return nil
+	}
+
+	rootOpts.passphrase, err = readKeyPassphrase(rootOpts.PrivKey)
+	return err
+}
+
+// readKeyring reads the configured keys.  The private key is held by the
+// agent if it's used.
+func readKeyring() (*keyring.Keyring, error) {
+	opts := &keyring.Options{
+		PubKeys:     rootOpts.PubKeys,
+		Keys:        rootOpts.Keys,
+		Groups:      rootOpts.Groups,
+		Transitions: rootOpts.Transitions,
+	}
+
+	sock := agentSocket()
+	if sock == "" {
+		opts.PrivKey = rootOpts.PrivKey
+		opts.Passphrase = rootOpts.passphrase
+		return keyring.Read(opts)
+	}
+
+	keys, err := keyring.Read(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keys.Private, err = agent.Dial(sock)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// readBlobKeyring is like readKeyring() but it returns the keys in the
+// format used by the blob package.
+func readBlobKeyring() (*blob.Keyring, error) {
+	keys, err := readKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return keys.Blob(), nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/illikainen/bambi/src/atomicfile"
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var passwdOpts struct {
+	input   string
+	remove  bool
+	oldPass []byte
+	newPass []byte
+}
+
+var passwdCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "Change or remove the passphrase for a private key",
+	Long: "Change or remove the passphrase for a private key.\n\n" +
+		"The configured private key is used unless --input is specified.\n",
+	PreRunE: passwdPreRun,
+	RunE:    passwdRun,
+}
+
+func init() {
+	flags := passwdCmd.Flags()
+
+	flags.StringVarP(&passwdOpts.input, "input", "i", "", "Private key to change the passphrase for")
+	flags.BoolVarP(&passwdOpts.remove, "remove", "", false,
+		"Remove the passphrase and store the private key in plaintext")
+
+	rootCmd.AddCommand(passwdCmd)
+}
+
+func passwdPreRun(_ *cobra.Command, _ []string) (err error) {
+	if passwdOpts.input == "" {
+		passwdOpts.input = rootOpts.PrivKey
+	}
+
+	if passwdOpts.input == "" {
+		return errors.Errorf("a private key must be configured or specified with --input")
+	}
+
+	passwdOpts.input, err = iofs.Expand(passwdOpts.input)
+	if err != nil {
+		return err
+	}
+
+	passwdOpts.oldPass, err = readKeyPassphrase(passwdOpts.input)
+	if err != nil {
+		return err
+	}
+
+	if !passwdOpts.remove {
+		passwdOpts.newPass, err = readNewPassphrase("New passphrase")
+		if err != nil {
+			return err
+		}
+
+		if len(passwdOpts.newPass) == 0 {
+			return errors.Errorf("the passphrase can't be empty (use --remove to remove it)")
+		}
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(passwdOpts.input, filepath.Dir(passwdOpts.input))
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func passwdRun(cmd *cobra.Command, _ []string) (err error) {
+	cmd.SilenceUsage = true
+
+	key, err := keyring.ReadPrivateKey(passwdOpts.input, passwdOpts.oldPass)
+	if err != nil {
+		return err
+	}
+
+	meta, err := keyring.ReadMetadata(passwdOpts.input)
+	if err != nil {
+		return err
+	}
+
+	cert, _, err := keyring.ReadCertificate(passwdOpts.input)
+	if err != nil {
+		return err
+	}
+
+	f, err := atomicfile.Create(passwdOpts.input)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(f.Close, &err)
+
+	err = keyring.WritePrivateKey(f.Name(), key, passwdOpts.newPass)
+	if err != nil {
+		return err
+	}
+
+	err = keyring.WriteMetadata(f.Name(), meta)
+	if err != nil {
+		return err
+	}
+
+	if cert != nil {
+		err = keyring.WriteCertificate(f.Name(), cert)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = f.Commit()
+	if err != nil {
+		return err
+	}
+
+	if passwdOpts.remove {
+		log.Infof("successfully removed the passphrase for %s", passwdOpts.input)
+	} else {
+		log.Infof("successfully changed the passphrase for %s", passwdOpts.input)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"github.com/illikainen/bambi/src/bambi"
+	"github.com/illikainen/bambi/src/keyring"
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+)
+
+type policyOptions struct {
+	requireSigners int
+	policy         string
+	cosignatures   string
+	signers        []string
+}
+
+// signaturePolicy returns the named policy from the config, if any, with
+// the threshold overridden by requireSigners.  Signers may be named by key
+// or group names.
+func signaturePolicy(keys *keyring.Keyring, opts *policyOptions) (*bambi.Policy, error) {
+	if opts.requireSigners < 0 {
+		return nil, errors.Errorf("invalid number of required signers: %d", opts.requireSigners)
+	}
+
+	if opts.policy == "" && opts.requireSigners == 0 {
+		return nil, nil
+	}
+
+	policy := &bambi.Policy{Threshold: opts.requireSigners, Identities: keys.Identities()}
+	if opts.policy != "" {
+		cfg, ok := rootOpts.Policies[opts.policy]
+		if !ok {
+			return nil, errors.Errorf("invalid policy: %s", opts.policy)
+		}
+
+		policy.Signers = keys.SignerFingerprints(cfg.Signers)
+		if opts.requireSigners == 0 {
+			policy.Threshold = cfg.Threshold
+		}
+	}
+
+	return policy, nil
+}
+
+// trustedSigners returns the fingerprints of the signers from the command
+// line, or from the config if none were specified.  The signing subkeys of
+// the signers are trusted as well.
+func trustedSigners(keys *keyring.Keyring, opts *policyOptions) []string {
+	if len(opts.signers) > 0 {
+		return keys.SignerFingerprints(opts.signers)
+	}
+	return keys.SignerFingerprints(rootOpts.TrustedSigners)
+}
+
+// readCosignatures returns nil if path doesn't exist.
+func readCosignatures(path string) (*signature.Envelope, error) {
+	exists, err := iofs.Exists(path)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return signature.Read(path, bambi.CosignType)
+}
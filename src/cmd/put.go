@@ -4,18 +4,20 @@ import (
 	"net/url"
 	"os"
 
-	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/auditlog"
+	"github.com/illikainen/bambi/src/bambi"
 
-	"github.com/illikainen/go-cryptor/src/blob"
 	"github.com/illikainen/go-netutils/src/sshx"
 	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/iofs"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var putOpts struct {
-	url        *url.URL
-	signedOnly bool
+	url          *url.URL
+	signedOnly   bool
+	allowExpired bool
 }
 
 var putCmd = &cobra.Command{
@@ -23,7 +25,8 @@ var putCmd = &cobra.Command{
 	Short: "Upload a sealed archive",
 	Long: "Upload a sealed archive.\n\n" +
 		"The file provided after <url> is verified as a signed and encrypted archive\n" +
-		"before uploading it to <url>.\n",
+		"before uploading it to <url>.  Expired archives aren't uploaded unless\n" +
+		"--allow-expired is specified.\n",
 	Args:    cobra.ExactArgs(2),
 	PreRunE: putPreRun,
 	RunE:    putRun,
@@ -35,6 +38,8 @@ func init() {
 	flags.BoolVarP(&putOpts.signedOnly, "signed-only", "s", false,
 		"Required if the archive is signed but not encrypted")
 
+	flags.BoolVarP(&putOpts.allowExpired, "allow-expired", "", false, "Upload blobs that have expired")
+
 	rootCmd.AddCommand(putCmd)
 }
 
@@ -43,7 +48,7 @@ func putPreRun(_ *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	ro = append(ro, args[1])
+	ro = append(ro, args[1], args[1]+bambi.CosignExt)
 
 	uri, err := url.Parse(args[0])
 	if err != nil {
@@ -67,13 +72,31 @@ func putPreRun(_ *cobra.Command, args []string) error {
 		return err
 	}
 
+	err = addAuditLogPaths()
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
 	return rootOpts.Sandbox.Confine()
 }
 
-func putRun(cmd *cobra.Command, args []string) error {
+func putRun(cmd *cobra.Command, args []string) (err error) {
 	cmd.SilenceUsage = true
 
-	keys, err := blob.ReadKeyring(rootOpts.PrivKey, rootOpts.PubKeys)
+	entry := &auditlog.Entry{Command: "put", Path: args[1], URL: putOpts.url.String()}
+	defer recordAudit(entry, &err)
+
+	keys, err := readBlobKeyring()
+	if err != nil {
+		return err
+	}
+
+	revocations, err := readRevocations(keys)
 	if err != nil {
 		return err
 	}
@@ -84,14 +107,31 @@ func putRun(cmd *cobra.Command, args []string) error {
 	}
 	defer errorx.Defer(f.Close, &err)
 
-	err = blob.Upload(putOpts.url, f, &blob.Options{
-		Type:      metadata.Name(),
-		Keyring:   keys,
-		Encrypted: !putOpts.signedOnly,
+	res, err := bambi.Put(cmd.Context(), putOpts.url, f, &bambi.Options{
+		Keyring:      keys,
+		Encrypted:    !putOpts.signedOnly,
+		AllowExpired: putOpts.allowExpired,
+		Revocations:  revocations,
 	})
 	if err != nil {
 		return err
 	}
+	entry.Signer = res.Signer.Fingerprint()
+	entry.Hashes = hashMap(res.Metadata.Hashes)
+
+	cosig := args[1] + bambi.CosignExt
+	exists, err := iofs.Exists(cosig)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		err = bambi.PutCosignatures(cmd.Context(), putOpts.url, cosig)
+		if err != nil {
+			return err
+		}
+		log.Infof("successfully uploaded cosignatures to %s%s", putOpts.url, bambi.CosignExt)
+	}
 
 	log.Infof("successfully uploaded sealed blob to %s", putOpts.url)
 	return nil
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/illikainen/bambi/src/atomicfile"
+	"github.com/illikainen/bambi/src/bambi"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var rekeyOpts struct {
+	input            string
+	output           string
+	addRecipients    []string
+	removeRecipients []string
+}
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Change the recipients of a sealed blob",
+	Long: "Change the recipients of a sealed blob.\n\n" +
+		"The blob is decrypted and encrypted again for the new recipients without\n" +
+		"unpacking its archive, and it's signed with the configured private key.\n" +
+		"Existing cosignatures aren't valid for the rekeyed blob.\n",
+	PreRunE: rekeyPreRun,
+	RunE:    rekeyRun,
+}
+
+func init() {
+	flags := rekeyCmd.Flags()
+
+	flags.StringVarP(&rekeyOpts.input, "input", "i", "", "Sealed blob to rekey")
+	fn.Must(rekeyCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&rekeyOpts.output, "output", "o", "", "Output file for the rekeyed blob")
+	fn.Must(rekeyCmd.MarkFlagRequired("output"))
+
+	flags.StringArrayVarP(&rekeyOpts.addRecipients, "add-recipient", "", nil,
+		"Encrypt for the key or group with this fingerprint or name (may be repeated)")
+	flags.StringArrayVarP(&rekeyOpts.removeRecipients, "remove-recipient", "", nil,
+		"Don't encrypt for the key or group with this fingerprint or name (may be repeated)")
+
+	rootCmd.AddCommand(rekeyCmd)
+}
+
+func rekeyPreRun(_ *cobra.Command, _ []string) error {
+	err := rootOpts.Sandbox.AddReadOnlyPath(rekeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(rekeyOpts.output, filepath.Dir(rekeyOpts.output))
+	if err != nil {
+		return err
+	}
+
+	err = removeOnInterrupt(atomicfile.PartialPath(rekeyOpts.output))
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func rekeyRun(cmd *cobra.Command, _ []string) (err error) {
+	cmd.SilenceUsage = true
+	defer cleanupOnInterrupt(cmd.Context(), &err)
+
+	if len(rekeyOpts.addRecipients) == 0 && len(rekeyOpts.removeRecipients) == 0 {
+		return errors.Errorf("at least one recipient must be added or removed")
+	}
+
+	keys, err := readKeyring()
+	if err != nil {
+		return err
+	}
+
+	revocations, err := readRevocations(keys.Blob())
+	if err != nil {
+		return err
+	}
+
+	added, err := keys.Select(rekeyOpts.addRecipients)
+	if err != nil {
+		return err
+	}
+
+	recipients := &bambi.Recipients{Add: added.Recipients()}
+	for _, key := range recipients.Add {
+		log.Infof("adding recipient %s", keys.Describe(key))
+	}
+
+	// Removed recipients may have been dropped from the keyring already, so
+	// unknown queries are treated as fingerprints.
+	for _, fpr := range keys.Fingerprints(rekeyOpts.removeRecipients) {
+		log.Infof("removing recipient %s", fpr)
+		recipients.Remove = append(recipients.Remove, fpr)
+	}
+
+	input, err := os.Open(rekeyOpts.input)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(input.Close, &err)
+
+	output, err := atomicfile.Create(rekeyOpts.output)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(output.Close, &err)
+
+	res, err := bambi.Rekey(cmd.Context(), input, output, recipients, &bambi.Options{
+		Keyring:     keys.Blob(),
+		Encrypted:   true,
+		Revocations: revocations,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = output.Commit()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("originally signed by: %s", keys.Describe(res.Signer))
+	log.Infof("successfully wrote rekeyed blob to %s", rekeyOpts.output)
+	return nil
+}
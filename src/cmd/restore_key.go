@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"github.com/illikainen/bambi/src/keyring"
+	"github.com/illikainen/bambi/src/mnemonic"
+
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var restoreKeyOpts struct {
+	output      string
+	fingerprint string
+	passphrase  bool
+	words       []byte
+	newPass     []byte
+}
+
+var restoreKeyCmd = &cobra.Command{
+	Use:   "restore-key",
+	Short: "Restore a keypair from its recovery words",
+	Long: "Restore a keypair from its recovery words.\n\n" +
+		"Keypairs that were generated with `genkey --mnemonic` are derived from\n" +
+		"their recovery words, so the same words always restore the same keypair.\n" +
+		"The words are read from the terminal, or from stdin if it isn't a\n" +
+		"terminal.\n",
+	PreRunE: restoreKeyPreRun,
+	RunE:    restoreKeyRun,
+}
+
+func init() {
+	flags := restoreKeyCmd.Flags()
+
+	flags.StringVarP(&restoreKeyOpts.output, "output", "o", "",
+		"Write the restored keypair to <output>.pub and <output>.priv")
+	fn.Must(restoreKeyCmd.MarkFlagRequired("output"))
+
+	flags.StringVarP(&restoreKeyOpts.fingerprint, "fingerprint", "f", "",
+		"Fail unless the restored keypair has this fingerprint")
+	flags.BoolVarP(&restoreKeyOpts.passphrase, "passphrase", "", false,
+		"Protect the private key with a passphrase")
+
+	rootCmd.AddCommand(restoreKeyCmd)
+}
+
+func restoreKeyPreRun(_ *cobra.Command, _ []string) (err error) {
+	restoreKeyOpts.words, err = readPassphrase("Recovery words")
+	if err != nil {
+		return err
+	}
+
+	if restoreKeyOpts.passphrase {
+		restoreKeyOpts.newPass, err = readNewPassphrase("Passphrase")
+		if err != nil {
+			return err
+		}
+
+		if len(restoreKeyOpts.newPass) == 0 {
+			return errors.Errorf("the passphrase can't be empty")
+		}
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(restoreKeyOpts.output+".pub", restoreKeyOpts.output+".priv")
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func restoreKeyRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	seed, err := mnemonic.Seed(string(restoreKeyOpts.words))
+	if err != nil {
+		return err
+	}
+
+	log.Infof("deriving the keypair; this may take a while...")
+	pubKey, privKey, err := keyring.DeriveKey(seed)
+	if err != nil {
+		return err
+	}
+
+	if restoreKeyOpts.fingerprint != "" && restoreKeyOpts.fingerprint != pubKey.Fingerprint() {
+		return errors.Errorf("the restored keypair has the fingerprint %s", pubKey.Fingerprint())
+	}
+
+	return writeKeypair(restoreKeyOpts.output, pubKey, privKey, restoreKeyOpts.newPass, &keyring.Metadata{})
+}
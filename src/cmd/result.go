@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/illikainen/bambi/src/bambi"
+	"github.com/illikainen/bambi/src/fingerprint"
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-cryptor/src/hasher"
+	"github.com/illikainen/go-utils/src/process"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Formats for the result of the verifying commands.  The text format is
+// only logged, while the json format is also written to stdout.
+var resultFormats = []string{"text", "json"}
+
+// report is the result of a verifying command in the json format.  It's
+// written even if the command fails.
+type report struct {
+	Verified   bool
+	Error      string `json:",omitempty"`
+	ExitCode   int
+	Signer     string
+	Signers    []string
+	Recipients []string
+	Hashes     map[string]string
+	Entries    int
+	Size       int64
+	Created    string `json:",omitempty"`
+	Expires    string `json:",omitempty"`
+	Labels     map[string]string
+}
+
+// logResult logs the result of verifying a blob.  The signers are also
+// shown as words if words is true.
+func logResult(keys *keyring.Keyring, res *bambi.Result, words bool) {
+	log.Infof("signed by: %s", describeSigner(keys, res.Signer, words))
+	for _, signer := range res.Signers {
+		if signer.Fingerprint() != res.Signer.Fingerprint() {
+			log.Infof("cosigned by: %s", describeSigner(keys, signer, words))
+		}
+	}
+	log.Infof("created: %s", res.Created.UTC().Format(time.RFC3339))
+	if res.Created.After(time.Now()) {
+		log.Warnf("the blob was signed in the future")
+	}
+	if !res.Expires.IsZero() {
+		if res.Expires.Before(time.Now()) {
+			log.Warnf("expired: %s", res.Expires.UTC().Format(time.RFC3339))
+		} else {
+			log.Infof("expires: %s", res.Expires.UTC().Format(time.RFC3339))
+		}
+	}
+	log.Infof("sha2-256: %s", res.Metadata.Hashes.SHA256)
+	log.Infof("sha3-512: %s", res.Metadata.Hashes.KECCAK512)
+	log.Infof("blake2b-512: %s", res.Metadata.Hashes.BLAKE2b512)
+	logLabels(res.Labels)
+}
+
+func describeSigner(keys *keyring.Keyring, signer cryptor.PublicKey, words bool) string {
+	desc := keys.Describe(signer)
+	if !words {
+		return desc
+	}
+
+	fprWords, err := fingerprint.Words(signer.Fingerprint())
+	if err != nil {
+		log.Debugf("%s", err)
+		return desc
+	}
+	return desc + " [" + fprWords + "]"
+}
+
+func logLabels(labels map[string]string) {
+	keys := []string{}
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		log.Infof("label: %s=%s", key, labels[key])
+	}
+}
+
+// addFormatFlag adds --format to a verifying command.  It isn't named
+// --output because -o/--output is the output file of get and unseal.
+func addFormatFlag(cmd *cobra.Command, format *string) {
+	cmd.Flags().StringVarP(format, "format", "", "text",
+		fmt.Sprintf("Format of the result (%s); not --output, which is the output file of get and unseal",
+			strings.Join(resultFormats, ", ")))
+}
+
+// setupFormat validates format.  The output of the sandboxed process is
+// passed through as-is for the json format, so it must be called before
+// the process is confined.
+func setupFormat(format string) error {
+	switch format {
+	case "text":
+		return nil
+	case "json":
+		rootOpts.Sandbox.SetStdout(process.ByteOutput)
+		return nil
+	}
+	return errors.Errorf("invalid format: %s (must be one of %s)", format, strings.Join(resultFormats, ", "))
+}
+
+func newReport() *report {
+	return &report{
+		Signers:    []string{},
+		Recipients: []string{},
+		Hashes:     map[string]string{},
+		Labels:     map[string]string{},
+	}
+}
+
+func (r *report) setResult(res *bambi.Result) {
+	r.Signer = res.Signer.Fingerprint()
+	for _, signer := range res.Signers {
+		r.Signers = append(r.Signers, signer.Fingerprint())
+	}
+
+	for fpr := range res.Metadata.Keys {
+		r.Recipients = append(r.Recipients, fpr)
+	}
+	sort.Strings(r.Recipients)
+
+	r.setHashes(res.Metadata.Hashes)
+	r.Entries = res.Entries
+	r.Size = res.Size
+	r.Created = res.Created.UTC().Format(time.RFC3339)
+	if !res.Expires.IsZero() {
+		r.Expires = res.Expires.UTC().Format(time.RFC3339)
+	}
+	if res.Labels != nil {
+		r.Labels = res.Labels
+	}
+}
+
+// setError records the signer of a blob that failed a check after its
+// signature was verified.
+func (r *report) setError(err error) {
+	var signerErr *bambi.SignerError
+	if errors.As(err, &signerErr) {
+		r.Signer = signerErr.Signer.Fingerprint()
+	}
+}
+
+func (r *report) setDetachedResult(res *bambi.DetachedResult) {
+	for _, signer := range res.Signers {
+		r.Signers = append(r.Signers, signer.Fingerprint())
+	}
+	if len(r.Signers) > 0 {
+		r.Signer = r.Signers[0]
+	}
+
+	r.setHashes(res.Statement.Hashes)
+	r.Created = time.Unix(res.Statement.Timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+func (r *report) setHashes(hashes *hasher.Writer) {
+	r.Hashes = hashMap(hashes)
+}
+
+// hashMap returns hashes by the names that they're logged with.
+func hashMap(hashes *hasher.Writer) map[string]string {
+	return map[string]string{
+		"sha2-256":    hashes.SHA256,
+		"sha3-512":    hashes.KECCAK512,
+		"blake2b-512": hashes.BLAKE2b512,
+	}
+}
+
+// writeReport writes r to stdout if format is json.  It's deferred by the
+// verifying commands so that the report includes the error from the
+// command.
+func writeReport(format string, r *report, err *error) {
+	if format != "json" {
+		return
+	}
+
+	r.Verified = *err == nil
+	if *err != nil {
+		r.Error = (*err).Error()
+		r.ExitCode = exitCode(nil, *err)
+	}
+
+	data, jsonErr := json.Marshal(r)
+	if jsonErr == nil {
+		_, jsonErr = fmt.Fprintf(os.Stdout, "%s\n", data)
+	}
+	if jsonErr != nil && *err == nil {
+		*err = jsonErr
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/illikainen/bambi/src/bambi"
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-cryptor/src/blob"
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var revokeOpts struct {
+	key    string
+	reason string
+}
+
+var revokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Add a key to the revocation list",
+	Long: "Add a key to the revocation list.\n\n" +
+		"The list is created if it doesn't exist, and it's signed with the\n" +
+		"configured private key.  Blobs, cosignatures and detached signatures by\n" +
+		"a revoked key are refused by every verifying command, regardless of\n" +
+		"when they claim to have been signed, because the signer chooses the\n" +
+		"signing time.  The list records when a key was added to it.\n\n" +
+		"The list must be signed by one of the `trusted-signers` in the\n" +
+		"configuration, or by the configured private key if there are none,\n" +
+		"and never by a key that it revokes.\n",
+	PreRunE: revokePreRun,
+	RunE:    revokeRun,
+}
+
+func init() {
+	flags := revokeCmd.Flags()
+
+	flags.StringVarP(&revokeOpts.key, "key", "k", "", "Fingerprint or name of the key to revoke")
+	fn.Must(revokeCmd.MarkFlagRequired("key"))
+
+	flags.StringVarP(&revokeOpts.reason, "reason", "", "", "Reason for the revocation")
+
+	rootCmd.AddCommand(revokeCmd)
+}
+
+func revokePreRun(_ *cobra.Command, _ []string) error {
+	if rootOpts.Revocations == "" {
+		return errors.Errorf("a revocation list must be configured")
+	}
+
+	err := rootOpts.Sandbox.AddReadWritePath(rootOpts.Revocations)
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func revokeRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	keys, err := readKeyring()
+	if err != nil {
+		return err
+	}
+
+	if keys.Private == nil {
+		return errors.Errorf("a private key must be configured to sign")
+	}
+
+	list := &bambi.RevocationList{}
+	exists, err := iofs.Exists(rootOpts.Revocations)
+	if err != nil {
+		return err
+	}
+	if exists {
+		list, err = readRevocations(keys.Blob())
+		if err != nil {
+			return err
+		}
+	}
+
+	// The key may have been dropped from the keyring already, so unknown
+	// keys are treated as fingerprints.
+	fpr := revokeOpts.key
+	selected, err := keys.Select([]string{revokeOpts.key})
+	if err == nil {
+		fpr = selected.Public[0].Fingerprint()
+	}
+
+	err = list.Revoke(fpr, time.Now().Unix(), revokeOpts.reason)
+	if err != nil {
+		return err
+	}
+
+	env, err := list.Sign(keys.Private)
+	if err != nil {
+		return err
+	}
+
+	err = env.Write(rootOpts.Revocations)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("successfully revoked %s in %s", fpr, rootOpts.Revocations)
+	return nil
+}
+
+// readRevocations returns the configured revocation list, or nil if no
+// list is configured.
+func readRevocations(keys *blob.Keyring) (*bambi.RevocationList, error) {
+	if rootOpts.Revocations == "" {
+		return nil, nil
+	}
+
+	authorities, err := revocationAuthorities(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return bambi.ReadRevocations(rootOpts.Revocations, keys.Public, authorities)
+}
+
+// revocationAuthorities returns the fingerprints of the keys that may sign
+// the revocation list: the trusted signers in the config, or the
+// configured private key if there are none.
+func revocationAuthorities(keys *blob.Keyring) ([]string, error) {
+	if len(rootOpts.TrustedSigners) > 0 {
+		// The public keys are enough to resolve the names of the signers.
+		pubKeys, err := keyring.Read(&keyring.Options{
+			PubKeys:     rootOpts.PubKeys,
+			Keys:        rootOpts.Keys,
+			Groups:      rootOpts.Groups,
+			Transitions: rootOpts.Transitions,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return pubKeys.SignerFingerprints(rootOpts.TrustedSigners), nil
+	}
+
+	if keys.Private == nil {
+		return nil, errors.Errorf("a private key or trusted signers must be configured to " +
+			"verify the revocation list")
+	}
+	return []string{keys.Private.Fingerprint()}, nil
+}
@@ -17,9 +17,10 @@ import (
 
 var rootOpts struct {
 	config.Config
-	config  string
-	Sandbox sandbox.Sandbox
-	sandbox string
+	config     string
+	Sandbox    sandbox.Sandbox
+	sandbox    string
+	passphrase []byte
 }
 
 var rootCmd = &cobra.Command{
@@ -29,7 +30,7 @@ var rootCmd = &cobra.Command{
 		err := rootPreRun(cmd, args)
 		if err != nil {
 			log.Tracef("%+v", err)
-			log.Fatalf("%s", err)
+			Fatal(cmd, err)
 		}
 	},
 }
@@ -53,10 +54,12 @@ func init() {
 		fmt.Sprintf("Verbosity (%s)", strings.Join(levels, ", ")))
 	flags.StringVarP(&rootOpts.PrivKey, "privkey", "", "", "Private key file")
 	flags.StringSliceVarP(&rootOpts.PubKeys, "pubkeys", "", nil, "Public key file(s)")
+	flags.StringVarP(&rootOpts.Revocations, "revocations", "", "", "Signed list of revoked keys")
+	flags.StringSliceVarP(&rootOpts.Transitions, "transitions", "", nil, "Signed key transition(s)")
 	flags.StringVarP(&rootOpts.sandbox, "sandbox", "", "", "Sandbox backend")
 }
 
-func rootPreRun(_ *cobra.Command, _ []string) error {
+func rootPreRun(cmd *cobra.Command, _ []string) error {
 	cfg, err := config.Read(rootOpts.config, &rootOpts.Config)
 	if err != nil {
 		return err
@@ -75,25 +78,42 @@ func rootPreRun(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
+	// The private key file isn't needed in the sandbox if the agent is
+	// used; only the socket is.
+	ro := append([]string{rootOpts.config, rootOpts.Revocations}, rootOpts.PubKeys...)
+	ro = append(ro, rootOpts.Transitions...)
+	for _, path := range rootOpts.Keys {
+		ro = append(ro, path)
+	}
+
+	rw := []string{}
+	if agentSocket() != "" {
+		rw = append(rw, agentSocket())
+	} else {
+		ro = append(ro, rootOpts.PrivKey)
+	}
+
 	switch backend {
 	case sandbox.BubblewrapSandbox:
-		rootOpts.Sandbox, err = sandbox.NewBubblewrap(&sandbox.BubblewrapOptions{
-			ReadOnlyPaths: append([]string{
-				rootOpts.config,
-				rootOpts.PrivKey,
-			}, rootOpts.PubKeys...),
-			ReadWritePaths:   nil,
+		bwrap, err := sandbox.NewBubblewrap(&sandbox.BubblewrapOptions{
+			ReadOnlyPaths:    ro,
+			ReadWritePaths:   rw,
 			Tmpfs:            true,
 			Devtmpfs:         true,
 			Procfs:           true,
 			AllowCommonPaths: true,
 			Stdin:            io.Reader(nil),
 			Stdout:           process.LogrusOutput,
-			Stderr:           process.LogrusOutput,
+			Stderr:           sandboxStderr,
 		})
 		if err != nil {
 			return err
 		}
+		rootOpts.Sandbox = &exitSandbox{Sandbox: bwrap}
+
+		if !sandbox.IsSandboxed() {
+			go awaitInterrupt(cmd.Context())
+		}
 	case sandbox.NoSandbox:
 		rootOpts.Sandbox, err = sandbox.NewNoop()
 		if err != nil {
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-utils/src/fn"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var rotateKeyOpts struct {
+	old     string
+	newKey  string
+	newPriv string
+	output  string
+	oldPass []byte
+	newPass []byte
+}
+
+var rotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Create a statement that a new key replaces an old key",
+	Long: "Create a statement that a new key replaces an old key.\n\n" +
+		"The statement is signed by both keys.  Add it to `transitions` in the\n" +
+		"configuration of everyone who trusts the old key: the new key is then\n" +
+		"trusted wherever the old key is, and blobs for the old key are sealed\n" +
+		"for the new key instead.\n\n" +
+		"The configured private key is the old key unless --old is specified,\n" +
+		"and the new private key is read from the .priv file next to the new\n" +
+		"public key unless --new-privkey is specified.\n",
+	PreRunE: rotateKeyPreRun,
+	RunE:    rotateKeyRun,
+}
+
+func init() {
+	flags := rotateKeyCmd.Flags()
+
+	flags.StringVarP(&rotateKeyOpts.old, "old", "", "", "Private key that is replaced")
+	flags.StringVarP(&rotateKeyOpts.newKey, "new", "", "", "Public key that replaces the old key")
+	fn.Must(rotateKeyCmd.MarkFlagRequired("new"))
+
+	flags.StringVarP(&rotateKeyOpts.newPriv, "new-privkey", "", "", "Private key for the new public key")
+	flags.StringVarP(&rotateKeyOpts.output, "output", "o", "", "Output file for the statement")
+	fn.Must(rotateKeyCmd.MarkFlagRequired("output"))
+
+	rootCmd.AddCommand(rotateKeyCmd)
+}
+
+func rotateKeyPreRun(_ *cobra.Command, _ []string) (err error) {
+	if rotateKeyOpts.old == "" {
+		rotateKeyOpts.old = rootOpts.PrivKey
+	}
+
+	if rotateKeyOpts.old == "" {
+		return errors.Errorf("a private key must be configured or specified with --old")
+	}
+
+	rotateKeyOpts.old, err = iofs.Expand(rotateKeyOpts.old)
+	if err != nil {
+		return err
+	}
+
+	rotateKeyOpts.newKey, err = iofs.Expand(rotateKeyOpts.newKey)
+	if err != nil {
+		return err
+	}
+
+	if rotateKeyOpts.newPriv == "" {
+		ext := filepath.Ext(rotateKeyOpts.newKey)
+		rotateKeyOpts.newPriv = strings.TrimSuffix(rotateKeyOpts.newKey, ext) + ".priv"
+	}
+
+	rotateKeyOpts.newPriv, err = iofs.Expand(rotateKeyOpts.newPriv)
+	if err != nil {
+		return err
+	}
+
+	rotateKeyOpts.oldPass, err = readKeyPassphrase(rotateKeyOpts.old)
+	if err != nil {
+		return err
+	}
+
+	rotateKeyOpts.newPass, err = readKeyPassphrase(rotateKeyOpts.newPriv)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(rotateKeyOpts.old, rotateKeyOpts.newKey, rotateKeyOpts.newPriv)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(rotateKeyOpts.output)
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func rotateKeyRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	// Subkeys are replaced by certifying a new subkey with the primary
+	// key.
+	for _, path := range []string{rotateKeyOpts.old, rotateKeyOpts.newKey, rotateKeyOpts.newPriv} {
+		_, cert, err := keyring.ReadCertificate(path)
+		if err != nil {
+			return err
+		}
+		if cert != nil {
+			return errors.Errorf("%s: subkeys can't be rotated", path)
+		}
+	}
+
+	old, err := keyring.ReadPrivateKey(rotateKeyOpts.old, rotateKeyOpts.oldPass)
+	if err != nil {
+		return err
+	}
+
+	newPub, err := asymmetric.ReadPublicKey(rotateKeyOpts.newKey)
+	if err != nil {
+		return err
+	}
+
+	newPriv, err := keyring.ReadPrivateKey(rotateKeyOpts.newPriv, rotateKeyOpts.newPass)
+	if err != nil {
+		return err
+	}
+
+	env, err := keyring.NewTransition(old, newPub, newPriv)
+	if err != nil {
+		return err
+	}
+
+	err = env.Write(rotateKeyOpts.output)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("%s is replaced by %s", old, newPub)
+	log.Infof("successfully wrote transition statement to %s", rotateKeyOpts.output)
+	return nil
+}
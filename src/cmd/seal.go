@@ -1,14 +1,19 @@
 package cmd
 
 import (
-	"os"
+	"io"
+	"path/filepath"
+	"time"
 
-	"github.com/illikainen/bambi/src/archive"
-	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/atomicfile"
+	"github.com/illikainen/bambi/src/auditlog"
+	"github.com/illikainen/bambi/src/bambi"
+	"github.com/illikainen/bambi/src/keyring"
 
-	"github.com/illikainen/go-cryptor/src/blob"
+	"github.com/illikainen/go-cryptor/src/cryptor"
 	"github.com/illikainen/go-utils/src/errorx"
 	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -16,6 +21,10 @@ import (
 var sealOpts struct {
 	output     string
 	signedOnly bool
+	labels     []string
+	recipients []string
+	expires    time.Duration
+	threshold  int
 }
 
 var sealCmd = &cobra.Command{
@@ -35,16 +44,48 @@ func init() {
 	flags.BoolVarP(&sealOpts.signedOnly, "signed-only", "s", false,
 		"Only sign the archive, don't encrypt it")
 
+	flags.StringArrayVarP(&sealOpts.labels, "label", "l", nil,
+		"Add a key=value label to the signed archive (may be repeated)")
+
+	flags.StringArrayVarP(&sealOpts.recipients, "recipient", "r", nil,
+		"Only encrypt for the key or group with this fingerprint or name (may be repeated)")
+
+	flags.DurationVarP(&sealOpts.expires, "expires", "", 0,
+		"Refuse to verify the blob after this duration (e.g. 720h)")
+
+	flags.IntVarP(&sealOpts.threshold, "threshold", "", 0,
+		"Require this many of the recipients to cooperate to unseal the archive")
+
 	rootCmd.AddCommand(sealCmd)
 }
 
 func sealPreRun(_ *cobra.Command, args []string) error {
+	if sealOpts.expires < 0 {
+		return errors.Errorf("invalid expiry duration: %s", sealOpts.expires)
+	}
+
 	err := rootOpts.Sandbox.AddReadOnlyPath(args...)
 	if err != nil {
 		return err
 	}
 
-	err = rootOpts.Sandbox.AddReadWritePath(sealOpts.output)
+	// The directory is needed for the temporary file and the lock.
+	err = rootOpts.Sandbox.AddReadWritePath(sealOpts.output, filepath.Dir(sealOpts.output))
+	if err != nil {
+		return err
+	}
+
+	err = removeOnInterrupt(atomicfile.PartialPath(sealOpts.output))
+	if err != nil {
+		return err
+	}
+
+	err = addAuditLogPaths()
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
 	if err != nil {
 		return err
 	}
@@ -54,35 +95,83 @@ func sealPreRun(_ *cobra.Command, args []string) error {
 
 func sealRun(cmd *cobra.Command, args []string) (err error) {
 	cmd.SilenceUsage = true
+	defer cleanupOnInterrupt(cmd.Context(), &err)
+
+	entry := &auditlog.Entry{Command: "seal", Path: sealOpts.output}
+	defer recordAudit(entry, &err)
 
-	keys, err := blob.ReadKeyring(rootOpts.PrivKey, rootOpts.PubKeys)
+	labels, err := parseLabels(sealOpts.labels)
 	if err != nil {
 		return err
 	}
 
-	output, err := os.Create(sealOpts.output)
+	keys, err := readKeyring()
+	if err != nil {
+		return err
+	}
+
+	if len(sealOpts.recipients) > 0 {
+		if sealOpts.signedOnly {
+			return errors.Errorf("recipients can't be specified for signed-only archives")
+		}
+
+		keys, err = keys.Select(sealOpts.recipients)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keys with an encryption subkey are encrypted for the subkey.
+	blobKeys := keys.Blob()
+	if !sealOpts.signedOnly {
+		blobKeys.Public = keys.Recipients()
+	}
+
+	if len(sealOpts.recipients) > 0 {
+		for _, key := range blobKeys.Public {
+			log.Infof("encrypting for %s", keys.Describe(key))
+		}
+	}
+
+	if sealOpts.threshold > 0 {
+		if sealOpts.signedOnly {
+			return errors.Errorf("a threshold can't be specified for signed-only archives")
+		}
+
+		recipient, err := bambi.NewThresholdRecipient(blobKeys.Public, sealOpts.threshold)
+		if err != nil {
+			return err
+		}
+		blobKeys.Public = []cryptor.PublicKey{recipient}
+
+		log.Infof("%d of %d recipients are required to unseal the archive",
+			recipient.Threshold, len(recipient.Recipients))
+	}
+
+	output, err := atomicfile.Create(sealOpts.output)
 	if err != nil {
 		return err
 	}
 	defer errorx.Defer(output.Close, &err)
 
-	blobber, err := blob.NewWriter(output, &blob.Options{
-		Type:      metadata.Name(),
-		Keyring:   keys,
+	err = bambi.Seal(cmd.Context(), output, args, &bambi.Options{
+		Keyring:   blobKeys,
 		Encrypted: !sealOpts.signedOnly,
+		Labels:    labels,
+		Expires:   sealOpts.expires,
 	})
 	if err != nil {
 		return err
 	}
-	defer errorx.Defer(blobber.Close, &err)
 
-	arch, err := archive.NewWriter(blobber)
+	// The audit entry is completed before the blob is committed so that
+	// seal doesn't fail after the blob was written.
+	err = sealAuditEntry(output, entry, keys)
 	if err != nil {
 		return err
 	}
-	defer errorx.Defer(arch.Close, &err)
 
-	err = arch.AddAll(args...)
+	err = output.Commit()
 	if err != nil {
 		return err
 	}
@@ -90,3 +179,28 @@ func sealRun(cmd *cobra.Command, args []string) (err error) {
 	log.Infof("successfully wrote sealed blob to %s", sealOpts.output)
 	return nil
 }
+
+// sealAuditEntry adds the signer and the hashes of the sealed blob in
+// output to entry.
+func sealAuditEntry(output io.ReadSeeker, entry *auditlog.Entry, keys *keyring.Keyring) error {
+	if rootOpts.AuditLog == "" {
+		return nil
+	}
+
+	if keys.Private != nil {
+		entry.Signer = keys.Private.Fingerprint()
+	}
+
+	_, err := output.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	meta, err := bambi.ReadMetadata(output, !sealOpts.signedOnly)
+	if err != nil {
+		return err
+	}
+
+	entry.Hashes = hashMap(meta.Hashes)
+	return nil
+}
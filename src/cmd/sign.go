@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/illikainen/bambi/src/bambi"
+
+	"github.com/illikainen/go-utils/src/errorx"
+	"github.com/illikainen/go-utils/src/fn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var signOpts struct {
+	input  string
+	output string
+}
+
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Create a detached signature for a file",
+	Long: "Create a detached signature for a file.\n\n" +
+		"The signed file is left as-is.  Use `verify --detached` to verify it.\n",
+	PreRunE: signPreRun,
+	RunE:    signRun,
+}
+
+func init() {
+	flags := signCmd.Flags()
+
+	flags.StringVarP(&signOpts.input, "input", "i", "", "File to sign")
+	fn.Must(signCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&signOpts.output, "output", "o", "", "Output file for the signature")
+	fn.Must(signCmd.MarkFlagRequired("output"))
+
+	rootCmd.AddCommand(signCmd)
+}
+
+func signPreRun(_ *cobra.Command, _ []string) error {
+	err := rootOpts.Sandbox.AddReadOnlyPath(signOpts.input)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadWritePath(signOpts.output)
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
+	if err != nil {
+		return err
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func signRun(cmd *cobra.Command, _ []string) (err error) {
+	cmd.SilenceUsage = true
+
+	keys, err := readBlobKeyring()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(signOpts.input)
+	if err != nil {
+		return err
+	}
+	defer errorx.Defer(f.Close, &err)
+
+	sig, err := bambi.Sign(cmd.Context(), f, &bambi.Options{Keyring: keys})
+	if err != nil {
+		return err
+	}
+
+	err = sig.Write(signOpts.output)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("successfully wrote signature for %s to %s", signOpts.input, signOpts.output)
+	return nil
+}
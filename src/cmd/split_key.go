@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/illikainen/bambi/src/keyring"
+
+	"github.com/illikainen/go-utils/src/fn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var splitKeyOpts struct {
+	input      string
+	output     string
+	shares     int
+	threshold  int
+	passphrase []byte
+}
+
+var splitKeyCmd = &cobra.Command{
+	Use:   "split-key",
+	Short: "Split a private key into shares",
+	Long: "Split a private key into shares.\n\n" +
+		"The key is split with Shamir's secret sharing so that any threshold of\n" +
+		"the shares can be combined into the key with combine-key, while fewer\n" +
+		"shares reveal nothing about it.  The shares aren't encrypted even if the\n" +
+		"key is.\n",
+	PreRunE: splitKeyPreRun,
+	RunE:    splitKeyRun,
+}
+
+func init() {
+	flags := splitKeyCmd.Flags()
+
+	flags.StringVarP(&splitKeyOpts.input, "input", "i", "", "Private key to split")
+	fn.Must(splitKeyCmd.MarkFlagRequired("input"))
+
+	flags.StringVarP(&splitKeyOpts.output, "output", "o", "",
+		"Write the shares to <output>.<n>.share (default: the input without its extension)")
+
+	flags.IntVarP(&splitKeyOpts.shares, "shares", "n", 0, "Number of shares")
+	fn.Must(splitKeyCmd.MarkFlagRequired("shares"))
+
+	flags.IntVarP(&splitKeyOpts.threshold, "threshold", "k", 0,
+		"Number of shares that are needed to combine them into the key")
+	fn.Must(splitKeyCmd.MarkFlagRequired("threshold"))
+
+	rootCmd.AddCommand(splitKeyCmd)
+}
+
+func splitKeyPreRun(_ *cobra.Command, _ []string) (err error) {
+	if splitKeyOpts.output == "" {
+		splitKeyOpts.output = strings.TrimSuffix(splitKeyOpts.input, filepath.Ext(splitKeyOpts.input))
+	}
+
+	splitKeyOpts.passphrase, err = readKeyPassphrase(splitKeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(splitKeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= splitKeyOpts.shares; i++ {
+		err = rootOpts.Sandbox.AddReadWritePath(sharePath(splitKeyOpts.output, i))
+		if err != nil {
+			return err
+		}
+	}
+
+	return rootOpts.Sandbox.Confine()
+}
+
+func splitKeyRun(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	key, err := keyring.ReadPrivateKey(splitKeyOpts.input, splitKeyOpts.passphrase)
+	if err != nil {
+		return err
+	}
+
+	meta, err := keyring.ReadMetadata(splitKeyOpts.input)
+	if err != nil {
+		return err
+	}
+
+	shares, err := keyring.SplitPrivateKey(key, meta, splitKeyOpts.shares, splitKeyOpts.threshold)
+	if err != nil {
+		return err
+	}
+
+	for _, share := range shares {
+		path := sharePath(splitKeyOpts.output, int(share.X))
+		err = share.Write(path)
+		if err != nil {
+			return err
+		}
+		log.Infof("wrote share %d of %d to %s", share.X, share.Count, path)
+	}
+
+	log.Infof("successfully split %s into %d shares with a threshold of %d", key, splitKeyOpts.shares,
+		splitKeyOpts.threshold)
+	return nil
+}
+
+func sharePath(output string, n int) string {
+	return fmt.Sprintf("%s.%d.share", output, n)
+}
@@ -3,20 +3,25 @@ package cmd
 import (
 	"os"
 
-	"github.com/illikainen/bambi/src/archive"
-	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/auditlog"
+	"github.com/illikainen/bambi/src/bambi"
+	"github.com/illikainen/bambi/src/keyring"
 
-	"github.com/illikainen/go-cryptor/src/blob"
 	"github.com/illikainen/go-utils/src/errorx"
 	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var unsealOpts struct {
-	input      string
-	output     string
-	signedOnly bool
+	input        string
+	output       string
+	signedOnly   bool
+	allowExpired bool
+	shares       []string
+	format       string
+	policyOptions
 }
 
 var unsealCmd = &cobra.Command{
@@ -38,11 +43,41 @@ func init() {
 	flags.BoolVarP(&unsealOpts.signedOnly, "signed-only", "s", false,
 		"Required if the archive is signed but not encrypted")
 
+	flags.BoolVarP(&unsealOpts.allowExpired, "allow-expired", "", false, "Accept blobs that have expired")
+
+	addFormatFlag(unsealCmd, &unsealOpts.format)
+
+	flags.StringArrayVarP(&unsealOpts.shares, "share", "", nil,
+		"Decrypt a threshold-encrypted archive with this share from decrypt-share (may be repeated)")
+
+	flags.StringArrayVarP(&unsealOpts.signers, "signer", "", nil,
+		"Only accept blobs signed by the key or group with this fingerprint or name (may be repeated)")
+
+	flags.IntVarP(&unsealOpts.requireSigners, "require-signers", "", 0,
+		"Require signatures by at least this many keys, including cosigners")
+	flags.StringVarP(&unsealOpts.policy, "policy", "", "", "Signature policy from the config to enforce")
+	flags.StringVarP(&unsealOpts.cosignatures, "cosignatures", "", "",
+		"File with cosignatures for the archive (default: <input>.cosig)")
+
 	rootCmd.AddCommand(unsealCmd)
 }
 
 func unsealPreRun(_ *cobra.Command, _ []string) error {
-	err := rootOpts.Sandbox.AddReadOnlyPath(unsealOpts.input)
+	err := setupFormat(unsealOpts.format)
+	if err != nil {
+		return err
+	}
+
+	if unsealOpts.cosignatures == "" {
+		unsealOpts.cosignatures = unsealOpts.input + bambi.CosignExt
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(unsealOpts.input, unsealOpts.cosignatures)
+	if err != nil {
+		return err
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(unsealOpts.shares...)
 	if err != nil {
 		return err
 	}
@@ -52,48 +87,116 @@ func unsealPreRun(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
+	err = addAuditLogPaths()
+	if err != nil {
+		return err
+	}
+
+	err = removeOnInterrupt(unsealOpts.output)
+	if err != nil {
+		return err
+	}
+
+	// The private key isn't used if the archive is decrypted with shares.
+	if len(unsealOpts.shares) == 0 {
+		err = readPrivKeyPassphrase()
+		if err != nil {
+			return err
+		}
+	}
+
 	return rootOpts.Sandbox.Confine()
 }
 
 func unsealRun(cmd *cobra.Command, _ []string) (err error) {
 	cmd.SilenceUsage = true
+	defer cleanupOnInterrupt(cmd.Context(), &err)
+
+	rep := newReport()
+	defer writeReport(unsealOpts.format, rep, &err)
+	defer recordReport(&auditlog.Entry{Command: "unseal", Path: unsealOpts.input}, rep, &err)
 
-	keys, err := blob.ReadKeyring(rootOpts.PrivKey, rootOpts.PubKeys)
+	cosig, err := readCosignatures(unsealOpts.cosignatures)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Open(unsealOpts.input)
+	keys, err := readUnsealKeyring()
 	if err != nil {
 		return err
 	}
-	defer errorx.Defer(f.Close, &err)
 
-	blobber, err := blob.NewReader(f, &blob.Options{
-		Type:      metadata.Name(),
-		Keyring:   keys,
-		Encrypted: !unsealOpts.signedOnly,
-	})
+	policy, err := signaturePolicy(keys, &unsealOpts.policyOptions)
 	if err != nil {
 		return err
 	}
-	log.Infof("signed by: %s", blobber.Signer)
-	log.Infof("sha2-256: %s", blobber.Metadata.Hashes.SHA256)
-	log.Infof("sha3-512: %s", blobber.Metadata.Hashes.KECCAK512)
-	log.Infof("blake2b-512: %s", blobber.Metadata.Hashes.BLAKE2b512)
 
-	arch, err := archive.NewReader(blobber)
+	revocations, err := readRevocations(keys.Blob())
 	if err != nil {
 		return err
 	}
-	defer errorx.Defer(arch.Close, &err)
 
-	err = arch.ExtractAll(unsealOpts.output)
+	f, err := os.Open(unsealOpts.input)
 	if err != nil {
 		return err
 	}
+	defer errorx.Defer(f.Close, &err)
 
-	log.Infof("successfully wrote unsealed blob to %s", unsealOpts.output)
+	res, err := bambi.Unseal(cmd.Context(), f, unsealOpts.output, &bambi.Options{
+		Keyring:        keys.Blob(),
+		Encrypted:      !unsealOpts.signedOnly,
+		AllowExpired:   unsealOpts.allowExpired,
+		Revocations:    revocations,
+		Cosignatures:   cosig,
+		Policy:         policy,
+		TrustedSigners: trustedSigners(keys, &unsealOpts.policyOptions),
+	})
+	if err != nil {
+		rep.setError(err)
+		return err
+	}
 
+	rep.setResult(res)
+	logResult(keys, res, false)
+	log.Infof("successfully wrote unsealed blob to %s", unsealOpts.output)
 	return nil
 }
+
+// readUnsealKeyring is like readKeyring() but the private key is replaced by
+// the combined shares if the archive is threshold-encrypted.
+func readUnsealKeyring() (*keyring.Keyring, error) {
+	if len(unsealOpts.shares) == 0 {
+		return readKeyring()
+	}
+
+	if unsealOpts.signedOnly {
+		return nil, errors.Errorf("shares can't be used for signed-only archives")
+	}
+
+	shares := []*bambi.DecryptionShare{}
+	for _, path := range unsealOpts.shares {
+		share, err := bambi.ReadDecryptionShare(path)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+
+	privKey, err := bambi.NewThresholdKey(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := keyring.Read(&keyring.Options{
+		PubKeys:     rootOpts.PubKeys,
+		Keys:        rootOpts.Keys,
+		Groups:      rootOpts.Groups,
+		Transitions: rootOpts.Transitions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys.Private = privKey
+	return keys, nil
+}
@@ -1,26 +1,51 @@
 package cmd
 
 import (
-	"io"
 	"os"
 
-	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/auditlog"
+	"github.com/illikainen/bambi/src/bambi"
+	"github.com/illikainen/bambi/src/keyring"
+	"github.com/illikainen/bambi/src/signature"
 
-	"github.com/illikainen/go-cryptor/src/blob"
 	"github.com/illikainen/go-utils/src/errorx"
 	"github.com/illikainen/go-utils/src/fn"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var verifyOpts struct {
-	input      string
-	signedOnly bool
+	input         string
+	signedOnly    bool
+	allowExpired  bool
+	requireLabels []string
+	detached      string
+	words         bool
+	format        string
+	expectSHA256  string
+	expectBLAKE2b string
+	checksums     string
+	policyOptions
 }
 
 var verifyCmd = &cobra.Command{
-	Use:     "verify",
-	Short:   "Verify a signed and encrypted archive",
+	Use:   "verify",
+	Short: "Verify a signed and encrypted archive",
+	Long: "Verify a signed and encrypted archive.\n\n" +
+		"The exit status is 3 for invalid signatures and hashes, 4 for untrusted\n" +
+		"or revoked signers, 5 for I/O errors and 2 for usage errors.  With\n" +
+		"--format json, the result is also written to stdout as a JSON object.\n" +
+		"The flag is --format rather than --output because -o/--output is the\n" +
+		"output file of unseal and get.\n\n" +
+		"Expected hashes are compared with the hashes of the whole --input file\n" +
+		"after the signature is verified, so a blob can be checked against the\n" +
+		"output of sha256sum, such as a checksum on a release page or in a\n" +
+		"SHA256SUMS file.  Entries in --checksums are matched by the path of\n" +
+		"--input, or by its base name if no path matches; more than one match is\n" +
+		"an error.  Note that the hashes that are shown for a blob are the signed\n" +
+		"hashes of its payload, which is everything after the signed header, so\n" +
+		"they don't match the hashes of the whole file.\n",
 	PreRunE: verifyPreRun,
 	RunE:    verifyRun,
 }
@@ -34,11 +59,64 @@ func init() {
 	flags.BoolVarP(&verifyOpts.signedOnly, "signed-only", "s", false,
 		"Required if the archive is signed but not encrypted")
 
+	flags.BoolVarP(&verifyOpts.allowExpired, "allow-expired", "", false, "Accept blobs that have expired")
+
+	flags.StringArrayVarP(&verifyOpts.requireLabels, "require-label", "", nil,
+		"Fail unless the archive has a matching key=value label (may be repeated)")
+
+	flags.StringVarP(&verifyOpts.detached, "detached", "", "",
+		"Verify --input with a detached signature instead of as an archive")
+
+	flags.BoolVarP(&verifyOpts.words, "words", "", false,
+		"Also show the fingerprints of the signers as words")
+
+	flags.StringVarP(&verifyOpts.expectSHA256, "expect-sha256", "", "",
+		"Fail unless the SHA-256 hash of --input matches this hash in hex or base64")
+	flags.StringVarP(&verifyOpts.expectBLAKE2b, "expect-blake2b", "", "",
+		"Fail unless the BLAKE2b-512 hash of --input matches this hash in hex or base64")
+	flags.StringVarP(&verifyOpts.checksums, "checksums", "", "",
+		"Fail unless the SHA-256 hash of --input matches its hash in this SHA256SUMS file")
+
+	addFormatFlag(verifyCmd, &verifyOpts.format)
+
+	flags.StringArrayVarP(&verifyOpts.signers, "signer", "", nil,
+		"Only accept blobs signed by the key or group with this fingerprint or name (may be repeated)")
+
+	flags.IntVarP(&verifyOpts.requireSigners, "require-signers", "", 0,
+		"Require signatures by at least this many keys, including cosigners")
+	flags.StringVarP(&verifyOpts.policy, "policy", "", "", "Signature policy from the config to enforce")
+	flags.StringVarP(&verifyOpts.cosignatures, "cosignatures", "", "",
+		"File with cosignatures for the archive (default: <input>.cosig)")
+
 	rootCmd.AddCommand(verifyCmd)
 }
 
 func verifyPreRun(_ *cobra.Command, _ []string) error {
-	err := rootOpts.Sandbox.AddReadOnlyPath(verifyOpts.input)
+	err := setupFormat(verifyOpts.format)
+	if err != nil {
+		return err
+	}
+
+	if verifyOpts.expectSHA256 != "" && verifyOpts.checksums != "" {
+		return errors.Errorf("--expect-sha256 can't be combined with --checksums")
+	}
+
+	if verifyOpts.cosignatures == "" {
+		verifyOpts.cosignatures = verifyOpts.input + bambi.CosignExt
+	}
+
+	err = rootOpts.Sandbox.AddReadOnlyPath(verifyOpts.input, verifyOpts.detached, verifyOpts.cosignatures,
+		verifyOpts.checksums)
+	if err != nil {
+		return err
+	}
+
+	err = addAuditLogPaths()
+	if err != nil {
+		return err
+	}
+
+	err = readPrivKeyPassphrase()
 	if err != nil {
 		return err
 	}
@@ -49,7 +127,31 @@ func verifyPreRun(_ *cobra.Command, _ []string) error {
 func verifyRun(cmd *cobra.Command, _ []string) (err error) {
 	cmd.SilenceUsage = true
 
-	keys, err := blob.ReadKeyring(rootOpts.PrivKey, rootOpts.PubKeys)
+	rep := newReport()
+	defer writeReport(verifyOpts.format, rep, &err)
+	defer recordReport(&auditlog.Entry{Command: "verify", Path: verifyOpts.input}, rep, &err)
+
+	required, err := parseLabels(verifyOpts.requireLabels)
+	if err != nil {
+		return err
+	}
+
+	expected, err := expectedHashes()
+	if err != nil {
+		return err
+	}
+
+	keys, err := readKeyring()
+	if err != nil {
+		return err
+	}
+
+	policy, err := signaturePolicy(keys, &verifyOpts.policyOptions)
+	if err != nil {
+		return err
+	}
+
+	revocations, err := readRevocations(keys.Blob())
 	if err != nil {
 		return err
 	}
@@ -60,25 +162,104 @@ func verifyRun(cmd *cobra.Command, _ []string) (err error) {
 	}
 	defer errorx.Defer(inf.Close, &err)
 
-	blobber, err := blob.NewReader(inf, &blob.Options{
-		Type:      metadata.Name(),
-		Keyring:   keys,
-		Encrypted: !verifyOpts.signedOnly,
-	})
+	if verifyOpts.detached != "" {
+		return verifyDetached(cmd, inf, keys, rep, &bambi.Options{
+			Keyring:        keys.Blob(),
+			Revocations:    revocations,
+			TrustedSigners: trustedSigners(keys, &verifyOpts.policyOptions),
+			ExpectedHashes: expected,
+		})
+	}
+
+	cosig, err := readCosignatures(verifyOpts.cosignatures)
 	if err != nil {
 		return err
 	}
 
-	// Not strictly needed because the blob is verified in NewReader().
-	_, err = io.Copy(io.Discard, blobber)
+	res, err := bambi.Verify(cmd.Context(), inf, &bambi.Options{
+		Keyring:        keys.Blob(),
+		Encrypted:      !verifyOpts.signedOnly,
+		AllowExpired:   verifyOpts.allowExpired,
+		Revocations:    revocations,
+		RequireLabels:  required,
+		Cosignatures:   cosig,
+		Policy:         policy,
+		TrustedSigners: trustedSigners(keys, &verifyOpts.policyOptions),
+		ExpectedHashes: expected,
+	})
 	if err != nil {
-		return nil
+		rep.setError(err)
+		return err
 	}
 
-	log.Infof("signed by: %s", blobber.Signer)
-	log.Infof("sha2-256: %s", blobber.Metadata.Hashes.SHA256)
-	log.Infof("sha3-512: %s", blobber.Metadata.Hashes.KECCAK512)
-	log.Infof("blake2b-512: %s", blobber.Metadata.Hashes.BLAKE2b512)
+	rep.setResult(res)
+	logResult(keys, res, verifyOpts.words)
 	log.Infof("successfully verified %s", verifyOpts.input)
 	return nil
 }
+
+func verifyDetached(cmd *cobra.Command, inf *os.File, keys *keyring.Keyring, rep *report,
+	opts *bambi.Options) error {
+	if len(verifyOpts.requireLabels) > 0 {
+		return errors.Errorf("labels can't be required for detached signatures")
+	}
+
+	if verifyOpts.requireSigners > 0 || verifyOpts.policy != "" {
+		return errors.Errorf("signature policies aren't supported for detached signatures")
+	}
+
+	sig, err := signature.Read(verifyOpts.detached, bambi.DetachedType)
+	if err != nil {
+		return err
+	}
+
+	res, err := bambi.VerifyDetached(cmd.Context(), inf, sig, opts)
+	if err != nil {
+		return err
+	}
+
+	rep.setDetachedResult(res)
+	for _, signer := range res.Signers {
+		log.Infof("signed by: %s", describeSigner(keys, signer, verifyOpts.words))
+	}
+	log.Infof("sha2-256: %s", res.Statement.Hashes.SHA256)
+	log.Infof("sha3-512: %s", res.Statement.Hashes.KECCAK512)
+	log.Infof("blake2b-512: %s", res.Statement.Hashes.BLAKE2b512)
+	log.Infof("successfully verified %s with %s", verifyOpts.input, verifyOpts.detached)
+	return nil
+}
+
+// expectedHashes returns the hashes that the signed hashes must match, or
+// nil if no hashes are expected.
+func expectedHashes() (*bambi.ExpectedHashes, error) {
+	if verifyOpts.expectSHA256 == "" && verifyOpts.expectBLAKE2b == "" && verifyOpts.checksums == "" {
+		return nil, nil
+	}
+
+	expected := &bambi.ExpectedHashes{}
+	if verifyOpts.expectSHA256 != "" {
+		hash, err := bambi.ParseSHA256(verifyOpts.expectSHA256)
+		if err != nil {
+			return nil, err
+		}
+		expected.SHA256 = hash
+	}
+
+	if verifyOpts.checksums != "" {
+		hash, err := bambi.ReadChecksum(verifyOpts.checksums, verifyOpts.input)
+		if err != nil {
+			return nil, err
+		}
+		expected.SHA256 = hash
+	}
+
+	if verifyOpts.expectBLAKE2b != "" {
+		hash, err := bambi.ParseBLAKE2b512(verifyOpts.expectBLAKE2b)
+		if err != nil {
+			return nil, err
+		}
+		expected.BLAKE2b512 = hash
+	}
+
+	return expected, nil
+}
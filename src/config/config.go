@@ -13,12 +13,26 @@ import (
 )
 
 type Config struct {
-	Profile   string `toml:"-"`
-	PrivKey   string
-	PubKeys   []string
-	Sandbox   string
-	Verbosity string
-	Profiles  map[string]Config `toml:"profile"`
+	Profile        string `toml:"-"`
+	PrivKey        string
+	PubKeys        []string
+	Keys           map[string]string   `toml:"keys"`
+	Groups         map[string][]string `toml:"groups"`
+	KeyDir         string
+	Revocations    string
+	Transitions    []string
+	AuditLog       string
+	AuditLogKey    string
+	TrustedSigners []string
+	Sandbox        string
+	Verbosity      string
+	Policies       map[string]Policy `toml:"policy"`
+	Profiles       map[string]Config `toml:"profile"`
+}
+
+type Policy struct {
+	Signers   []string
+	Threshold int
 }
 
 func Read(path string, overrides *Config) (*Config, error) {
@@ -69,3 +83,24 @@ func ConfigFile() (string, error) {
 
 	return filepath.Join(dir, "config.toml"), nil
 }
+
+// KeyDir is the default directory for keys.  Keys in it are listed by
+// `keys list` but they're only used if they're configured.
+func KeyDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "keys"), nil
+}
+
+// AuditLogKey is the default key for the audit log.
+func AuditLogKey() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "auditlog.key"), nil
+}
@@ -0,0 +1,152 @@
+package fingerprint
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/illikainen/bambi/src/mnemonic"
+	"github.com/illikainen/bambi/src/qrcode"
+
+	"github.com/pkg/errors"
+)
+
+// Formats are the supported representations of a fingerprint.  Base64 is
+// how fingerprints are shown everywhere else.
+var Formats = []string{"base64", "hex", "words", "randomart", "qr"}
+
+// Fingerprints are 64 bytes, so only a prefix is shown as words to keep
+// them short enough to read aloud.
+const (
+	wordBytes     = 16
+	hexGroupSize  = 4
+	hexGroupsLine = 8
+	artWidth      = 17
+	artHeight     = 9
+	artSymbols    = " .o+=*BOX@%&#/^SE"
+	artTitle      = "[bambi]"
+)
+
+// Format returns the lines of fpr in format.
+func Format(fpr string, format string) ([]string, error) {
+	switch format {
+	case "base64":
+		return []string{fpr}, nil
+	case "hex":
+		return Hex(fpr)
+	case "words":
+		words, err := Words(fpr)
+		if err != nil {
+			return nil, err
+		}
+		return []string{words}, nil
+	case "randomart":
+		return Randomart(fpr)
+	case "qr":
+		code, err := qrcode.Encode([]byte(fpr))
+		if err != nil {
+			return nil, err
+		}
+		return code.Lines(), nil
+	}
+	return nil, errors.Errorf("invalid format: %s (must be one of %s)", format, strings.Join(Formats, ", "))
+}
+
+// Hex returns fpr as groups of hex digits.
+func Hex(fpr string) ([]string, error) {
+	data, err := decode(fpr)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := strings.ToUpper(hex.EncodeToString(data))
+	groups := []string{}
+	for i := 0; i < len(encoded); i += hexGroupSize {
+		groups = append(groups, encoded[i:clamp(i+hexGroupSize, 0, len(encoded))])
+	}
+
+	lines := []string{}
+	for i := 0; i < len(groups); i += hexGroupsLine {
+		lines = append(lines, strings.Join(groups[i:clamp(i+hexGroupsLine, 0, len(groups))], " "))
+	}
+	return lines, nil
+}
+
+// Words returns the first 128 bits of fpr as 12 words from the same word
+// list as the recovery words.
+func Words(fpr string) (string, error) {
+	data, err := decode(fpr)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < wordBytes {
+		return "", errors.Errorf("%s: the fingerprint is too short", fpr)
+	}
+
+	return mnemonic.Encode(data[:wordBytes])
+}
+
+// Randomart returns fpr as a box that's drawn with the "drunken bishop"
+// algorithm used by OpenSSH.
+func Randomart(fpr string) ([]string, error) {
+	data, err := decode(fpr)
+	if err != nil {
+		return nil, err
+	}
+
+	field := [artWidth][artHeight]int{}
+	x := artWidth / 2
+	y := artHeight / 2
+	startX, startY := x, y
+
+	for _, b := range data {
+		for i := 0; i < 4; i++ {
+			dx, dy := -1, -1
+			if b&1 == 1 {
+				dx = 1
+			}
+			if b&2 == 2 {
+				dy = 1
+			}
+			x = clamp(x+dx, 0, artWidth-1)
+			y = clamp(y+dy, 0, artHeight-1)
+
+			// The start and end markers are the last two symbols.
+			field[x][y] = clamp(field[x][y]+1, 0, len(artSymbols)-3)
+			b >>= 2
+		}
+	}
+	field[startX][startY] = len(artSymbols) - 2
+	field[x][y] = len(artSymbols) - 1
+
+	pad := artWidth - len(artTitle)
+	lines := []string{"+" + strings.Repeat("-", pad/2) + artTitle + strings.Repeat("-", pad-pad/2) + "+"}
+	for row := 0; row < artHeight; row++ {
+		line := "|"
+		for col := 0; col < artWidth; col++ {
+			line += string(artSymbols[field[col][row]])
+		}
+		lines = append(lines, line+"|")
+	}
+	lines = append(lines, "+"+strings.Repeat("-", artWidth)+"+")
+	return lines, nil
+}
+
+func decode(fpr string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(fpr)
+	if err != nil {
+		return nil, errors.Errorf("%s: invalid fingerprint", fpr)
+	}
+	return data, nil
+}
+
+func clamp(value int, low int, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
@@ -0,0 +1,259 @@
+package keyformat
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ssh"
+)
+
+// Bambi keys consist of an Ed25519 signing key together with an RSA
+// signing key and NaCl and RSA encryption keys.  Other ecosystems only
+// understand the Ed25519 key, so that's what is exported.  Signatures made
+// with an exported key can't be verified as bambi signatures because those
+// also require an RSA signature.
+const (
+	OpenSSH  = "openssh"
+	Minisign = "minisign"
+	Signify  = "signify"
+)
+
+var Formats = []string{OpenSSH, Minisign, Signify}
+
+// ExportPublicKey returns the Ed25519 signing key in key in the given
+// format.
+func ExportPublicKey(key cryptor.PublicKey, format string, comment string) ([]byte, error) {
+	pub, err := signingKey[ed25519.PublicKey](key, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case OpenSSH:
+		sshKey, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+
+		line := bytes.TrimSuffix(ssh.MarshalAuthorizedKey(sshKey), []byte("\n"))
+		return []byte(fmt.Sprintf("%s %s\n", line, comment)), nil
+	case Minisign:
+		id := keyID(pub)
+		data := concat([]byte("Ed"), id, pub)
+		return encodeBox(fmt.Sprintf("minisign public key %X", reverse(id)), data), nil
+	case Signify:
+		data := concat([]byte("Ed"), keyID(pub), pub)
+		return encodeBox(fmt.Sprintf("%s public key", comment), data), nil
+	default:
+		return nil, errors.Errorf("unsupported key format: %s", format)
+	}
+}
+
+// ExportPrivateKey returns the Ed25519 signing key in key in the given
+// format.  The key is only encrypted if the format is OpenSSH and the
+// passphrase isn't empty.
+func ExportPrivateKey(key cryptor.PrivateKey, format string, comment string,
+	passphrase []byte) ([]byte, error) {
+	priv, err := signingKey[ed25519.PrivateKey](key, ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) > 0 && format != OpenSSH {
+		return nil, errors.Errorf("%s keys can't be exported with a passphrase", format)
+	}
+
+	pub, ok := priv.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, cryptor.ErrInvalidKeyType
+	}
+
+	switch format {
+	case OpenSSH:
+		var block *pem.Block
+		if len(passphrase) > 0 {
+			block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, comment, passphrase)
+		} else {
+			block, err = ssh.MarshalPrivateKey(priv, comment)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(block), nil
+	case Minisign:
+		// Unencrypted minisign keys have a zero KDF algorithm and the
+		// secret key is followed by a BLAKE2b checksum.
+		id := keyID(pub)
+		chk := blake2b.Sum256(concat([]byte("Ed"), id, priv))
+		data := concat([]byte("Ed"), []byte{0, 0}, []byte("B2"), make([]byte, 32),
+			make([]byte, 8), make([]byte, 8), id, priv, chk[:])
+		return encodeBox("minisign secret key", data), nil
+	case Signify:
+		// Unencrypted signify keys have zero KDF rounds.  The checksum
+		// is the start of the SHA-512 digest of the secret key.
+		chk := sha512.Sum512(priv)
+		data := concat([]byte("Ed"), []byte("BK"), make([]byte, 4), make([]byte, 16),
+			chk[:8], keyID(pub), priv)
+		return encodeBox(fmt.Sprintf("%s secret key", comment), data), nil
+	default:
+		return nil, errors.Errorf("unsupported key format: %s", format)
+	}
+}
+
+// ImportPrivateKey creates a bambi keypair with the Ed25519 key in data
+// as its signing key.  The rest of the keypair is generated.  Only OpenSSH
+// keys are supported.
+func ImportPrivateKey(data []byte, format string, passphrase []byte,
+	delay time.Duration) (cryptor.PublicKey, cryptor.PrivateKey, error) {
+	if format != OpenSSH {
+		return nil, nil, errors.Errorf("%s keys can't be imported", format)
+	}
+
+	var raw any
+	var err error
+	if len(passphrase) > 0 {
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(data, passphrase)
+	} else {
+		raw, err = ssh.ParseRawPrivateKey(data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	priv, ok := raw.(*ed25519.PrivateKey)
+	if !ok {
+		return nil, nil, errors.Errorf("only ed25519 keys can be imported")
+	}
+
+	pub, ok := priv.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, nil, cryptor.ErrInvalidKeyType
+	}
+
+	genPub, genPriv, err := asymmetric.GenerateKey(delay)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubKey := &asymmetric.PublicKeyContainer{}
+	err = replaceSigningKey(genPub, pub, pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privKey := &asymmetric.PrivateKeyContainer{}
+	err = replaceSigningKey(genPriv, *priv, privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pubKey, privKey, nil
+}
+
+// IsEncrypted returns true if the OpenSSH key in data is protected by a
+// passphrase.
+func IsEncrypted(data []byte) bool {
+	_, err := ssh.ParseRawPrivateKey(data)
+	missing := &ssh.PassphraseMissingError{}
+	return errors.As(err, &missing)
+}
+
+// containerJSON is the part of the JSON representation of a key container
+// with the Ed25519 key.
+type containerJSON struct {
+	NaCl struct {
+		Sign []byte
+	}
+}
+
+func signingKey[T ~[]byte](key any, size int) (T, error) {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	container := &containerJSON{}
+	err = json.Unmarshal(data, container)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(container.NaCl.Sign) != size {
+		return nil, cryptor.ErrInvalidKeyType
+	}
+
+	return T(container.NaCl.Sign), nil
+}
+
+func replaceSigningKey(generated any, key []byte, out json.Unmarshaler) error {
+	data, err := json.Marshal(generated)
+	if err != nil {
+		return err
+	}
+
+	container := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &container)
+	if err != nil {
+		return err
+	}
+
+	nacl := map[string]json.RawMessage{}
+	err = json.Unmarshal(container["NaCl"], &nacl)
+	if err != nil {
+		return err
+	}
+
+	nacl["Sign"], err = json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	container["NaCl"], err = json.Marshal(nacl)
+	if err != nil {
+		return err
+	}
+
+	data, err = json.Marshal(container)
+	if err != nil {
+		return err
+	}
+
+	return out.UnmarshalJSON(data)
+}
+
+// keyID derives an 8-byte key ID from the public key.  Minisign and signify
+// use random key IDs, but a derived ID lets the same key be exported more
+// than once.
+func keyID(pub ed25519.PublicKey) []byte {
+	sum := sha256.Sum256(pub)
+	return sum[:8]
+}
+
+func encodeBox(comment string, data []byte) []byte {
+	comment = strings.ReplaceAll(comment, "\n", " ")
+	encoded := base64.StdEncoding.EncodeToString(data)
+	return []byte(fmt.Sprintf("untrusted comment: %s\n%s\n", comment, encoded))
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// reverse returns a reversed copy of data.  Minisign shows the key ID as a
+// little-endian integer.
+func reverse(data []byte) []byte {
+	return binary.BigEndian.AppendUint64(nil, binary.LittleEndian.Uint64(data))
+}
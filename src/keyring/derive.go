@@ -0,0 +1,268 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"math/big"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	cryptorrsa "github.com/illikainen/go-cryptor/src/asymmetric/rsa"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Derived keys must be identical every time they're derived from the same
+// seed, so nothing about the derivation may ever change.  Each key is
+// derived from its own HKDF-SHA512 output.  The RSA primes are found by
+// searching a ChaCha20 keystream because crypto/rsa doesn't generate keys
+// deterministically.
+const (
+	deriveInfo    = "bambi.derived-key."
+	rsaExponent   = 65537
+	primeRounds   = 20
+	derivedKeyLen = 32
+)
+
+type derivedKeys struct {
+	Type int
+	NaCl struct {
+		Sign    []byte
+		Encrypt []byte
+	}
+	RSA struct {
+		Sign    []byte
+		Encrypt []byte
+	}
+}
+
+// DeriveKey deterministically derives a keypair from seed.
+func DeriveKey(seed []byte) (cryptor.PublicKey, cryptor.PrivateKey, error) {
+	if len(seed) < derivedKeyLen {
+		return nil, nil, errors.Errorf("the seed is too short")
+	}
+
+	pub := &derivedKeys{Type: cryptor.PublicKeyType}
+	priv := &derivedKeys{Type: cryptor.PrivateKeyType}
+
+	signSeed, err := deriveBytes(seed, "nacl-sign")
+	if err != nil {
+		return nil, nil, err
+	}
+	signPriv := ed25519.NewKeyFromSeed(signSeed)
+	signPub, ok := signPriv.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, nil, cryptor.ErrInvalidKeyType
+	}
+	priv.NaCl.Sign = signPriv
+	pub.NaCl.Sign = signPub
+
+	encPriv, err := deriveBytes(seed, "nacl-encrypt")
+	if err != nil {
+		return nil, nil, err
+	}
+	priv.NaCl.Encrypt = encPriv
+	pub.NaCl.Encrypt, err = curve25519.X25519(encPriv, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsaSign, err := deriveRSAKey(seed, "rsa-sign")
+	if err != nil {
+		return nil, nil, err
+	}
+	priv.RSA.Sign = x509.MarshalPKCS1PrivateKey(rsaSign)
+	pub.RSA.Sign = x509.MarshalPKCS1PublicKey(&rsaSign.PublicKey)
+
+	rsaEnc, err := deriveRSAKey(seed, "rsa-encrypt")
+	if err != nil {
+		return nil, nil, err
+	}
+	priv.RSA.Encrypt = x509.MarshalPKCS1PrivateKey(rsaEnc)
+	pub.RSA.Encrypt = x509.MarshalPKCS1PublicKey(&rsaEnc.PublicKey)
+
+	pubKey := &asymmetric.PublicKeyContainer{}
+	err = unmarshalDerived(pub, pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privKey := &asymmetric.PrivateKeyContainer{}
+	err = unmarshalDerived(priv, privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pubKey.Fingerprint() != privKey.Fingerprint() {
+		return nil, nil, errors.Errorf("the derived keys don't match")
+	}
+
+	return pubKey, privKey, nil
+}
+
+// PublicKeyFor returns the public key of priv.  It's used for private keys
+// that were restored without their public key.
+func PublicKeyFor(priv cryptor.PrivateKey) (cryptor.PublicKey, error) {
+	data, err := json.Marshal(priv)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(data)
+
+	keys := &derivedKeys{}
+	err = json.Unmarshal(data, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeDerived(keys)
+
+	if keys.Type != cryptor.PrivateKeyType || len(keys.NaCl.Sign) != ed25519.PrivateKeySize {
+		return nil, cryptor.ErrInvalidKeyType
+	}
+
+	pub := &derivedKeys{Type: cryptor.PublicKeyType}
+	signPub, ok := ed25519.PrivateKey(keys.NaCl.Sign).Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, cryptor.ErrInvalidKeyType
+	}
+	pub.NaCl.Sign = signPub
+
+	pub.NaCl.Encrypt, err = curve25519.X25519(keys.NaCl.Encrypt, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaSign, err := x509.ParsePKCS1PrivateKey(keys.RSA.Sign)
+	if err != nil {
+		return nil, err
+	}
+	pub.RSA.Sign = x509.MarshalPKCS1PublicKey(&rsaSign.PublicKey)
+
+	rsaEnc, err := x509.ParsePKCS1PrivateKey(keys.RSA.Encrypt)
+	if err != nil {
+		return nil, err
+	}
+	pub.RSA.Encrypt = x509.MarshalPKCS1PublicKey(&rsaEnc.PublicKey)
+
+	pubKey := &asymmetric.PublicKeyContainer{}
+	err = unmarshalDerived(pub, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if pubKey.Fingerprint() != priv.Fingerprint() {
+		return nil, errors.Errorf("the public key doesn't match the private key")
+	}
+	return pubKey, nil
+}
+
+func wipeDerived(keys *derivedKeys) {
+	wipe(keys.NaCl.Sign)
+	wipe(keys.NaCl.Encrypt)
+	wipe(keys.RSA.Sign)
+	wipe(keys.RSA.Encrypt)
+}
+
+func deriveBytes(seed []byte, purpose string) ([]byte, error) {
+	out := make([]byte, derivedKeyLen)
+	_, err := io.ReadFull(hkdf.New(sha512.New, seed, nil, []byte(deriveInfo+purpose)), out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func deriveRSAKey(seed []byte, purpose string) (*rsa.PrivateKey, error) {
+	key, err := deriveBytes(seed, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(key, make([]byte, chacha20.NonceSize))
+	if err != nil {
+		return nil, err
+	}
+
+	one := big.NewInt(1)
+	e := big.NewInt(rsaExponent)
+	for {
+		p := derivePrime(stream, cryptorrsa.KeySize/2)
+		q := derivePrime(stream, cryptorrsa.KeySize/2)
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != cryptorrsa.KeySize {
+			continue
+		}
+
+		pm1 := new(big.Int).Sub(p, one)
+		qm1 := new(big.Int).Sub(q, one)
+		phi := new(big.Int).Mul(pm1, qm1)
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+
+		priv := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: rsaExponent},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		priv.Precompute()
+
+		err = priv.Validate()
+		if err != nil {
+			return nil, err
+		}
+		return priv, nil
+	}
+}
+
+// derivePrime returns the first prime in the keystream with the two most
+// significant bits set, so that the product of two primes has 2*bits bits.
+func derivePrime(stream *chacha20.Cipher, bits int) *big.Int {
+	buf := make([]byte, bits/8)
+	candidate := new(big.Int)
+	e := big.NewInt(rsaExponent)
+	gcd := new(big.Int)
+
+	for {
+		for i := range buf {
+			buf[i] = 0
+		}
+		stream.XORKeyStream(buf, buf)
+		buf[0] |= 0xc0
+		buf[len(buf)-1] |= 1
+
+		candidate.SetBytes(buf)
+		if !candidate.ProbablyPrime(primeRounds) {
+			continue
+		}
+
+		pm1 := new(big.Int).Sub(candidate, big.NewInt(1))
+		if gcd.GCD(nil, nil, e, pm1).Cmp(big.NewInt(1)) == 0 {
+			return new(big.Int).Set(candidate)
+		}
+	}
+}
+
+// unmarshalDerived converts the raw derived keys to a key container.  The
+// keys are marshaled as base64 strings, which is how every key in a
+// container is stored.
+func unmarshalDerived(keys *derivedKeys, out json.Unmarshaler) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	defer wipe(data)
+
+	return out.UnmarshalJSON(data)
+}
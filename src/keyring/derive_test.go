@@ -0,0 +1,82 @@
+package keyring
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+)
+
+// The fingerprint of the keypair derived from derivedSeed.  It must never
+// change, or keys restored from a mnemonic won't match the original keys.
+const derivedFingerprint = "SyOh7kLVOjeE1OlSUCvJuEh1KKjnZvT3h473/VoHa3ZBnd7lNPpeTIy92JOYofEBYDi52i+" +
+	"MMHF1t4mGtBsLLw=="
+
+var derivedSeed = bytes.Repeat([]byte{0x42}, 64)
+
+var derived struct {
+	once sync.Once
+	pub  cryptor.PublicKey
+	priv cryptor.PrivateKey
+	err  error
+}
+
+// derivedKey returns the keypair for derivedSeed.  Deriving the RSA keys
+// is slow, so it's only done once.
+func derivedKey(t *testing.T) (cryptor.PublicKey, cryptor.PrivateKey) {
+	t.Helper()
+
+	derived.once.Do(func() {
+		derived.pub, derived.priv, derived.err = DeriveKey(derivedSeed)
+	})
+	if derived.err != nil {
+		t.Fatal(derived.err)
+	}
+	return derived.pub, derived.priv
+}
+
+func TestDeriveKey(t *testing.T) {
+	pub, priv := derivedKey(t)
+
+	if pub.Fingerprint() != derivedFingerprint || priv.Fingerprint() != derivedFingerprint {
+		t.Fatalf("got %s and %s, want %s", pub.Fingerprint(), priv.Fingerprint(), derivedFingerprint)
+	}
+
+	restored, err := PublicKeyFor(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.String() != pub.String() {
+		t.Fatalf("PublicKeyFor returned %s, want %s", restored, pub)
+	}
+
+	message := []byte("message")
+	sig, err := priv.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = restored.Verify(message, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := restored.Encrypt(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := priv.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, message) {
+		t.Fatalf("got %q, want %q", plaintext, message)
+	}
+}
+
+func TestDeriveKeyShortSeed(t *testing.T) {
+	_, _, err := DeriveKey(derivedSeed[:derivedKeyLen-1])
+	if err == nil {
+		t.Fatal("DeriveKey accepted a short seed")
+	}
+}
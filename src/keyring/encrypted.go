@@ -0,0 +1,238 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/stringx"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encrypted private keys are stored in the same base64-encoded JSON
+// format as plaintext keys.  The plaintext key is encrypted with
+// XChaCha20-Poly1305 with a key derived from the passphrase with Argon2id.
+const encryptedFormat = "bambi.encrypted-private-key"
+
+type encryptedKey struct {
+	Format string
+
+	// Fingerprint lets the key be identified without the passphrase.  It
+	// isn't authenticated but it's compared with the decrypted key.
+	Fingerprint string `json:",omitempty"`
+
+	KDF        *kdfParams
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+type kdfParams struct {
+	Name    string
+	Salt    []byte
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+const (
+	kdfName    = "argon2id"
+	kdfTime    = 3
+	kdfMemory  = 256 * 1024
+	kdfThreads = 4
+	kdfSaltLen = 32
+
+	// Upper bounds for the parameters of keys that are read, to avoid
+	// exhausting the memory or the CPU on a malicious key file.
+	kdfMaxTime   = 64
+	kdfMaxMemory = 4 * 1024 * 1024
+)
+
+var ErrPassphraseRequired = errors.New("a passphrase is required for the private key")
+
+// IsEncrypted returns true if the private key in path is protected by a
+// passphrase.
+func IsEncrypted(path string) (bool, error) {
+	enc, err := readEncryptedKey(path)
+	if err != nil {
+		return false, err
+	}
+	return enc != nil, nil
+}
+
+// ReadPrivateKey is like asymmetric.ReadPrivateKey() but it supports keys
+// that are protected by a passphrase.
+func ReadPrivateKey(path string, passphrase []byte) (cryptor.PrivateKey, error) {
+	enc, err := readEncryptedKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if enc == nil {
+		return asymmetric.ReadPrivateKey(path)
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.Wrap(ErrPassphraseRequired, path)
+	}
+
+	aead, err := enc.KDF.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(enc.Nonce) != aead.NonceSize() {
+		return nil, errors.Errorf("%s: invalid nonce", path)
+	}
+
+	plaintext, err := aead.Open(nil, enc.Nonce, enc.Ciphertext, []byte(encryptedFormat))
+	if err != nil {
+		return nil, errors.Errorf("%s: invalid passphrase", path)
+	}
+	defer wipe(plaintext)
+
+	if !bytes.Equal(stringx.Sanitize(plaintext), plaintext) {
+		return nil, errors.Errorf("%s contains invalid characters", path)
+	}
+
+	key := &asymmetric.PrivateKeyContainer{}
+	err = json.Unmarshal(plaintext, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Type != cryptor.PrivateKeyType {
+		return nil, cryptor.ErrInvalidKeyType
+	}
+
+	if enc.Fingerprint != "" && enc.Fingerprint != key.Fingerprint() {
+		return nil, errors.Errorf("%s: the fingerprint doesn't match the key", path)
+	}
+
+	return key, nil
+}
+
+// WritePrivateKey writes key to path.  The key is encrypted unless the
+// passphrase is empty.
+func WritePrivateKey(path string, key cryptor.PrivateKey, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return key.Write(path)
+	}
+
+	plaintext, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	defer wipe(plaintext)
+
+	params := &kdfParams{
+		Name:    kdfName,
+		Salt:    make([]byte, kdfSaltLen),
+		Time:    kdfTime,
+		Memory:  kdfMemory,
+		Threads: kdfThreads,
+	}
+	_, err = rand.Read(params.Salt)
+	if err != nil {
+		return err
+	}
+
+	aead, err := params.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&encryptedKey{
+		Format:      encryptedFormat,
+		Fingerprint: key.Fingerprint(),
+		KDF:         params,
+		Nonce:       nonce,
+		Ciphertext:  aead.Seal(nil, nonce, plaintext, []byte(encryptedFormat)),
+	})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	encoded := append([]byte(base64.StdEncoding.EncodeToString(data)), '\n')
+	err = os.WriteFile(path, encoded, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("%s: wrote encrypted %s", path, key)
+	return nil
+}
+
+// readEncryptedKey returns nil if the key in path isn't encrypted.
+func readEncryptedKey(path string) (*encryptedKey, error) {
+	data, err := iofs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(stringx.Sanitize(data), data) {
+		return nil, errors.Errorf("%s contains invalid characters", path)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &encryptedKey{}
+	err = json.Unmarshal(decoded, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	if enc.Format == "" {
+		return nil, nil
+	}
+
+	if enc.Format != encryptedFormat {
+		return nil, errors.Errorf("%s: unsupported key format: %s", path, enc.Format)
+	}
+
+	if enc.KDF == nil {
+		return nil, errors.Errorf("%s: missing KDF parameters", path)
+	}
+
+	return enc, nil
+}
+
+func (p *kdfParams) cipher(passphrase []byte) (cipher.AEAD, error) {
+	if p.Name != kdfName {
+		return nil, errors.Errorf("unsupported KDF: %s", p.Name)
+	}
+
+	if len(p.Salt) < kdfSaltLen || p.Time < 1 || p.Time > kdfMaxTime ||
+		p.Memory < 8*uint32(p.Threads) || p.Memory > kdfMaxMemory || p.Threads < 1 {
+		return nil, errors.Errorf("invalid KDF parameters")
+	}
+
+	key := argon2.IDKey(passphrase, p.Salt, p.Time, p.Memory, p.Threads, chacha20poly1305.KeySize)
+	defer wipe(key)
+
+	return chacha20poly1305.NewX(key)
+}
+
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
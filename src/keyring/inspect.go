@@ -0,0 +1,82 @@
+package keyring
+
+import (
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+)
+
+type Info struct {
+	Path      string
+	Private   bool
+	Encrypted bool
+	Legacy    bool
+
+	// Fingerprint is empty for encrypted keys that were written before
+	// the fingerprint was stored next to the encrypted key.
+	Fingerprint string
+	Metadata    *Metadata
+
+	// Certificate is set for subkeys.  It isn't verified.
+	Certificate *Certificate
+}
+
+// Inspect identifies the key in path.  Encrypted private keys are
+// identified without the passphrase.
+func Inspect(path string) (*Info, error) {
+	path, err := iofs.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = iofs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Path: path, Metadata: &Metadata{}}
+
+	enc, err := readEncryptedKey(path)
+	if err == nil && enc != nil {
+		info.Private = true
+		info.Encrypted = true
+		info.Fingerprint = enc.Fingerprint
+		return info, info.readFields()
+	}
+
+	if pub, err := asymmetric.ReadPublicKey(path); err == nil {
+		info.Fingerprint = pub.Fingerprint()
+		return info, info.readFields()
+	}
+
+	if priv, err := asymmetric.ReadPrivateKey(path); err == nil {
+		info.Private = true
+		info.Fingerprint = priv.Fingerprint()
+		return info, info.readFields()
+	}
+
+	// Legacy keys are raw binary keys without metadata.
+	info.Legacy = true
+	if pub, err := asymmetric.ReadPublicKeyLegacy(path); err == nil {
+		info.Fingerprint = pub.Fingerprint()
+		return info, nil
+	}
+
+	if priv, err := asymmetric.ReadPrivateKeyLegacy(path); err == nil {
+		info.Private = true
+		info.Fingerprint = priv.Fingerprint()
+		return info, nil
+	}
+
+	return nil, errors.Errorf("%s: unrecognized key format", path)
+}
+
+func (i *Info) readFields() (err error) {
+	i.Metadata, err = ReadMetadata(i.Path)
+	if err != nil {
+		return err
+	}
+
+	_, i.Certificate, err = ReadCertificate(i.Path)
+	return err
+}
@@ -0,0 +1,362 @@
+package keyring
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/blob"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+)
+
+type PublicKey struct {
+	cryptor.PublicKey
+	Name string
+	Path string
+
+	// Certificate is set if the key is a subkey of another key in the
+	// keyring.
+	Certificate *Certificate
+
+	// Predecessor is the fingerprint of the key that this key replaces
+	// according to a transition statement.
+	Predecessor string
+
+	env *signature.Envelope
+}
+
+type Keyring struct {
+	Public  []*PublicKey
+	Private cryptor.PrivateKey
+	Groups  map[string][]string
+
+	// all are the public keys that the keyring was read with, which may
+	// be more than Public after Select().
+	all []*PublicKey
+}
+
+type Options struct {
+	PrivKey string
+	PubKeys []string
+
+	// Keys maps names to public key files.  Keys in PubKeys are named
+	// after their file name without its extension.
+	Keys map[string]string
+
+	// Groups maps group names to members.  A member is the name or the
+	// fingerprint of a key, or the name of another group.
+	Groups map[string][]string
+
+	// Transitions are statements that replace keys in the keyring with
+	// new keys.
+	Transitions []string
+
+	// Passphrase is only used if the private key is encrypted.
+	Passphrase []byte
+}
+
+// Read is like blob.ReadKeyring() but it keeps track of the name of each
+// public key and of the configured groups.
+func Read(opts *Options) (*Keyring, error) {
+	keys := &Keyring{Groups: opts.Groups}
+
+	for _, elt := range opts.PubKeys {
+		base := filepath.Base(elt)
+		err := keys.add(strings.TrimSuffix(base, filepath.Ext(base)), elt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := []string{}
+	for name := range opts.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := opts.Groups[name]; ok {
+			return nil, errors.Errorf("%s: the name is used for both a key and a group", name)
+		}
+
+		err := keys.add(name, opts.Keys[name])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := keys.transition(opts.Transitions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = keys.certify()
+	if err != nil {
+		return nil, err
+	}
+	keys.link()
+	keys.all = keys.Public
+
+	if opts.PrivKey != "" {
+		path, err := iofs.Expand(opts.PrivKey)
+		if err != nil {
+			return nil, err
+		}
+
+		keys.Private, err = ReadPrivateKey(path, opts.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		// The certificate of a private subkey isn't verified because it
+		// only restricts what the key can be used for.
+		_, cert, err := ReadCertificate(path)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil {
+			keys.Private = &privateSubkey{PrivateKey: keys.Private, cert: cert}
+		}
+	}
+
+	return keys, nil
+}
+
+// Select returns a keyring with the public keys that match any of the
+// queries.  A query matches a key by fingerprint or by name, or every
+// member of a group by the name of the group.  An error is returned if a
+// query doesn't match any key.
+func (k *Keyring) Select(queries []string) (*Keyring, error) {
+	selected := &Keyring{Private: k.Private, Groups: k.Groups, all: k.everything()}
+
+	for _, query := range queries {
+		matches, err := k.lookup(query, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range matches {
+			if !selected.contains(key) {
+				selected.Public = append(selected.Public, key)
+			}
+		}
+	}
+
+	return selected, nil
+}
+
+// SignerFingerprints is like Fingerprints() but the keys that replace the
+// matching keys and the signing subkeys of every such key are included, so
+// that a primary key can be trusted without trusting each of its subkeys
+// and successors.
+func (k *Keyring) SignerFingerprints(queries []string) []string {
+	fprs := []string{}
+	for _, fpr := range k.Fingerprints(queries) {
+		matches := []string{fpr}
+		for _, key := range k.successors(fpr) {
+			matches = append(matches, key.Fingerprint())
+		}
+
+		for _, match := range matches {
+			fprs = append(fprs, match)
+			for _, key := range k.subkeys(match, UsageSign) {
+				fprs = append(fprs, key.Fingerprint())
+			}
+		}
+	}
+	return fprs
+}
+
+// Recipients returns the public keys to encrypt for.  Keys that have been
+// replaced through a transition are replaced by their newest successor,
+// keys with an encryption subkey are replaced by their newest encryption
+// subkey, and signing subkeys are left out.
+func (k *Keyring) Recipients() []cryptor.PublicKey {
+	pub := []cryptor.PublicKey{}
+	for _, key := range k.Public {
+		// Subkeys are only used through their primary key if it's in
+		// the keyring.
+		if key.Certificate != nil &&
+			(key.Certificate.Usage != UsageEncrypt || k.lookupKey(key.Certificate.Primary) != nil) {
+			continue
+		}
+
+		key = k.successor(key)
+		var newest *PublicKey
+		for _, sub := range k.subkeys(key.Fingerprint(), UsageEncrypt) {
+			if newest == nil || sub.Certificate.Created > newest.Certificate.Created {
+				newest = sub
+			}
+		}
+		if newest != nil {
+			key = newest
+		}
+
+		if findPublicKey(pub, key.Fingerprint()) == nil {
+			pub = append(pub, key.PublicKey)
+		}
+	}
+	return pub
+}
+
+// Fingerprints returns the fingerprints of the keys that match each
+// query.  Queries that don't match any key are returned as-is because they
+// may be fingerprints of keys that aren't in the keyring.
+func (k *Keyring) Fingerprints(queries []string) []string {
+	fprs := []string{}
+	for _, query := range queries {
+		matches, err := k.lookup(query, map[string]bool{})
+		if err != nil {
+			fprs = append(fprs, query)
+			continue
+		}
+
+		for _, key := range matches {
+			fprs = append(fprs, key.Fingerprint())
+		}
+	}
+	return fprs
+}
+
+// Describe returns the name and the fingerprint of key if it's in the
+// keyring.
+func (k *Keyring) Describe(key cryptor.PublicKey) string {
+	for _, elt := range k.everything() {
+		if elt.Fingerprint() == key.Fingerprint() {
+			desc := fmt.Sprintf("%s (%s)", elt.Name, key.Fingerprint())
+			if elt.Certificate != nil {
+				desc += ", " + k.describeSubkey(elt.Certificate)
+			}
+			if elt.Predecessor != "" {
+				desc += ", replaces " + elt.Predecessor
+			}
+			return desc
+		}
+	}
+	return key.String()
+}
+
+// Blob returns the keyring in the format used by the blob package.
+func (k *Keyring) Blob() *blob.Keyring {
+	pub := []cryptor.PublicKey{}
+	for _, key := range k.Public {
+		pub = append(pub, key.PublicKey)
+	}
+
+	return &blob.Keyring{
+		Public:  pub,
+		Private: k.Private,
+	}
+}
+
+// subkeys returns the subkeys of the key with the fingerprint fpr that are
+// certified for usage.
+func (k *Keyring) subkeys(fpr string, usage string) []*PublicKey {
+	keys := []*PublicKey{}
+	for _, key := range k.everything() {
+		if key.Certificate != nil && key.Certificate.Primary == fpr && key.Certificate.Usage == usage {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// lookupKey returns the public key in the keyring with the fingerprint
+// fpr, or nil if there isn't one.
+func (k *Keyring) lookupKey(fpr string) *PublicKey {
+	for _, key := range k.Public {
+		if key.Fingerprint() == fpr {
+			return key
+		}
+	}
+	return nil
+}
+
+func (k *Keyring) everything() []*PublicKey {
+	if k.all == nil {
+		return k.Public
+	}
+	return k.all
+}
+
+func (k *Keyring) contains(key *PublicKey) bool {
+	for _, elt := range k.Public {
+		if elt.Fingerprint() == key.Fingerprint() {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *Keyring) add(name string, path string) error {
+	path, err := iofs.Expand(path)
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := asymmetric.ReadPublicKey(path)
+	if err != nil {
+		return err
+	}
+
+	// A key may be both in the list of public keys and named in the
+	// config, in which case the configured name is used.
+	for _, elt := range k.Public {
+		if elt.Fingerprint() == pubkey.Fingerprint() {
+			elt.Name = name
+			return nil
+		}
+	}
+
+	env, _, err := ReadCertificate(path)
+	if err != nil {
+		return err
+	}
+
+	k.Public = append(k.Public, &PublicKey{
+		PublicKey: pubkey,
+		Name:      name,
+		Path:      path,
+		env:       env,
+	})
+	return nil
+}
+
+func (k *Keyring) lookup(query string, seen map[string]bool) ([]*PublicKey, error) {
+	if members, ok := k.Groups[query]; ok {
+		if seen[query] {
+			return nil, errors.Errorf("%s: the group includes itself", query)
+		}
+		seen[query] = true
+
+		keys := []*PublicKey{}
+		for _, member := range members {
+			matches, err := k.lookup(member, seen)
+			if err != nil {
+				return nil, errors.Errorf("%s: %s", query, err)
+			}
+			keys = append(keys, matches...)
+		}
+
+		delete(seen, query)
+		return keys, nil
+	}
+
+	keys := []*PublicKey{}
+	for _, key := range k.Public {
+		if key.Fingerprint() == query || key.Name == query {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.Errorf("%s: no such key in the keyring", query)
+	}
+	return keys, nil
+}
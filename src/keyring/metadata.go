@@ -0,0 +1,153 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/stringx"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Metadata is stored next to the key material in the JSON of a key file.
+// It's ignored when the key is read, and it isn't authenticated, so it
+// should only be used to give a key some human context.
+type Metadata struct {
+	Name    string `json:",omitempty"`
+	Comment string `json:",omitempty"`
+	Created int64  `json:",omitempty"`
+	Usage   string `json:",omitempty"`
+}
+
+const metadataKey = "Metadata"
+
+// NewMetadata returns metadata for a key that's created now.
+func NewMetadata(name string, comment string, usage string) *Metadata {
+	return &Metadata{
+		Name:    name,
+		Comment: comment,
+		Created: time.Now().Unix(),
+		Usage:   usage,
+	}
+}
+
+// Empty returns true if no metadata is set.
+func (m *Metadata) Empty() bool {
+	return m == nil || *m == Metadata{}
+}
+
+// CreatedTime returns the creation time, or the zero time if it's unknown.
+func (m *Metadata) CreatedTime() time.Time {
+	if m.Created == 0 {
+		return time.Time{}
+	}
+	return time.Unix(m.Created, 0)
+}
+
+// Validate returns an error if the metadata can't be stored in a key file.
+// Key files may only contain printable ASCII characters.
+func (m *Metadata) Validate() error {
+	for _, value := range []string{m.Name, m.Comment, m.Usage} {
+		if stringx.Sanitize(value) != value || strings.Contains(value, "\n") {
+			return errors.Errorf("%q contains invalid characters", value)
+		}
+	}
+	return nil
+}
+
+// ReadMetadata returns the metadata in the key file in path.  The returned
+// metadata is empty if the key doesn't have any.
+func ReadMetadata(path string) (*Metadata, error) {
+	fields, err := readKeyFields(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{}
+	if raw, ok := fields[metadataKey]; ok {
+		err = json.Unmarshal(raw, meta)
+		if err != nil {
+			return nil, errors.Errorf("%s: invalid metadata: %s", path, err)
+		}
+	}
+	return meta, nil
+}
+
+// WriteMetadata replaces the metadata in the key file in path.  The key
+// material is left as-is, so it works for encrypted private keys as well.
+func WriteMetadata(path string, meta *Metadata) error {
+	err := meta.Validate()
+	if err != nil {
+		return err
+	}
+
+	if meta.Empty() {
+		return writeKeyField(path, metadataKey, nil)
+	}
+	return writeKeyField(path, metadataKey, meta)
+}
+
+// writeKeyField replaces a field next to the key material in the key file
+// in path, or removes it if value is nil.  The file is left as-is if a
+// field that doesn't exist is removed.
+func writeKeyField(path string, key string, value any) error {
+	fields, err := readKeyFields(path)
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		if _, ok := fields[key]; !ok {
+			return nil
+		}
+		delete(fields, key)
+	} else {
+		fields[key], err = json.Marshal(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	encoded := append([]byte(base64.StdEncoding.EncodeToString(data)), '\n')
+	err = os.WriteFile(path, encoded, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("%s: wrote %s", path, strings.ToLower(key))
+	return nil
+}
+
+func readKeyFields(path string) (map[string]json.RawMessage, error) {
+	data, err := iofs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(stringx.Sanitize(data), data) {
+		return nil, errors.Errorf("%s contains invalid characters", path)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(decoded, &fields)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
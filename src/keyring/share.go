@@ -0,0 +1,167 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+
+	"github.com/illikainen/bambi/src/shamir"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/stringx"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Shares are stored in the same base64-encoded JSON format as keys.  The
+// ID is random for each split so that shares from different splits of the
+// same key aren't mixed up.
+const shareFormat = "bambi.private-key-share"
+
+const shareIDSize = 16
+
+type KeyShare struct {
+	Format      string
+	ID          []byte
+	Fingerprint string
+	Threshold   int
+	Count       int
+	X           byte
+	Data        []byte
+	Metadata    *Metadata `json:",omitempty"`
+}
+
+// SplitPrivateKey splits key into n shares of which any threshold are
+// needed to combine them into the key.
+func SplitPrivateKey(key cryptor.PrivateKey, meta *Metadata, n int, threshold int) ([]*KeyShare, error) {
+	plaintext, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(plaintext)
+
+	parts, err := shamir.Split(plaintext, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, shareIDSize)
+	_, err = rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.Empty() {
+		meta = nil
+	}
+
+	shares := []*KeyShare{}
+	for _, part := range parts {
+		shares = append(shares, &KeyShare{
+			Format:      shareFormat,
+			ID:          id,
+			Fingerprint: key.Fingerprint(),
+			Threshold:   threshold,
+			Count:       n,
+			X:           part.X,
+			Data:        part.Data,
+			Metadata:    meta,
+		})
+	}
+
+	return shares, nil
+}
+
+// CombinePrivateKey combines shares into the private key that they were
+// split from.  The key is verified against the fingerprint in the shares.
+func CombinePrivateKey(shares []*KeyShare) (cryptor.PrivateKey, *Metadata, error) {
+	if len(shares) == 0 {
+		return nil, nil, errors.Errorf("no shares")
+	}
+
+	first := shares[0]
+	parts := []*shamir.Share{}
+	for _, share := range shares {
+		if !bytes.Equal(share.ID, first.ID) || share.Fingerprint != first.Fingerprint ||
+			share.Threshold != first.Threshold {
+			return nil, nil, errors.Errorf("the shares are from different splits")
+		}
+		parts = append(parts, &shamir.Share{X: share.X, Data: share.Data})
+	}
+
+	if len(shares) < first.Threshold {
+		return nil, nil, errors.Errorf("%d of %d shares are required", first.Threshold, first.Count)
+	}
+
+	plaintext, err := shamir.Combine(parts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer wipe(plaintext)
+
+	key := &asymmetric.PrivateKeyContainer{}
+	err = json.Unmarshal(plaintext, key)
+	if err != nil {
+		return nil, nil, errors.Errorf("the shares don't combine into a key: %s", err)
+	}
+
+	if key.Type != cryptor.PrivateKeyType || key.Fingerprint() != first.Fingerprint {
+		return nil, nil, errors.Errorf("the combined key doesn't match the fingerprint in the shares")
+	}
+
+	meta := first.Metadata
+	if meta == nil {
+		meta = &Metadata{}
+	}
+	return key, meta, nil
+}
+
+// ReadKeyShare reads a share that was written by KeyShare.Write().
+func ReadKeyShare(path string) (*KeyShare, error) {
+	data, err := iofs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(stringx.Sanitize(data), data) {
+		return nil, errors.Errorf("%s contains invalid characters", path)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	share := &KeyShare{}
+	err = json.Unmarshal(decoded, share)
+	if err != nil {
+		return nil, err
+	}
+
+	if share.Format != shareFormat {
+		return nil, errors.Errorf("%s: not a key share", path)
+	}
+
+	return share, nil
+}
+
+func (s *KeyShare) Write(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	encoded := append([]byte(base64.StdEncoding.EncodeToString(data)), '\n')
+	err = os.WriteFile(path, encoded, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("%s: wrote share %d of %d for %s", path, s.X, s.Count, s.Fingerprint)
+	return nil
+}
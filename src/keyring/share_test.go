@@ -0,0 +1,87 @@
+package keyring
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitCombinePrivateKey(t *testing.T) {
+	_, priv := derivedKey(t)
+	meta := &Metadata{}
+
+	shares, err := SplitPrivateKey(priv, meta, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, subset := range [][]*KeyShare{
+		{shares[0], shares[1], shares[2]},
+		{shares[0], shares[1], shares[3]},
+		{shares[0], shares[2], shares[3]},
+		{shares[1], shares[2], shares[3]},
+		{shares[3], shares[1], shares[0]},
+		shares,
+	} {
+		key, _, err := CombinePrivateKey(subset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.Fingerprint() != priv.Fingerprint() {
+			t.Fatalf("got %s, want %s", key.Fingerprint(), priv.Fingerprint())
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "share")
+	err = shares[2].Write(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadKeyShare(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := CombinePrivateKey([]*KeyShare{shares[0], shares[1], read})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Fingerprint() != priv.Fingerprint() {
+		t.Fatalf("got %s, want %s", key.Fingerprint(), priv.Fingerprint())
+	}
+}
+
+func TestCombinePrivateKeyInvalid(t *testing.T) {
+	_, priv := derivedKey(t)
+	meta := &Metadata{}
+
+	shares, err := SplitPrivateKey(priv, meta, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := SplitPrivateKey(priv, meta, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := *shares[2]
+	tampered.Data = append([]byte{}, tampered.Data...)
+	tampered.Data[0] ^= 1
+
+	tests := []struct {
+		name   string
+		shares []*KeyShare
+	}{
+		{"none", nil},
+		{"too few", shares[:2]},
+		{"duplicate", []*KeyShare{shares[0], shares[1], shares[1]}},
+		{"different splits", []*KeyShare{shares[0], shares[1], other[2]}},
+		{"tampered", []*KeyShare{shares[0], shares[1], &tampered}},
+	}
+
+	for _, test := range tests {
+		_, _, err := CombinePrivateKey(test.shares)
+		if err == nil {
+			t.Errorf("%s: CombinePrivateKey succeeded", test.name)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package keyring
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Subkeys are ordinary keypairs that a primary key has certified for a
+// single purpose.  The certificate is stored next to the key material in
+// the key files of the subkey, so a subkey is configured like any other
+// key.  Only the primary key has to be trusted; its subkeys are accepted
+// for their purpose for as long as their certificates are valid.
+const (
+	UsageSign    = "sign"
+	UsageEncrypt = "encrypt"
+
+	certificateKey = "Certificate"
+)
+
+var Usages = []string{UsageSign, UsageEncrypt}
+
+// CertificateType is the envelope type for subkey certificates.
+var CertificateType = metadata.Name() + ".subkey-certificate"
+
+type Certificate struct {
+	Primary string
+	Subkey  string
+	Usage   string
+	Created int64
+	Expires int64 `json:",omitempty"`
+}
+
+// Certify returns a certificate for subkey that's signed by primary.  The
+// certificate never expires if expires is 0.
+func Certify(primary cryptor.PrivateKey, subkey cryptor.PublicKey, usage string,
+	expires time.Duration) (*signature.Envelope, error) {
+	err := ValidateUsage(usage)
+	if err != nil {
+		return nil, err
+	}
+
+	if expires < 0 {
+		return nil, errors.Errorf("invalid expiry duration: %s", expires)
+	}
+
+	if primary.Fingerprint() == subkey.Fingerprint() {
+		return nil, errors.Errorf("a key can't certify itself")
+	}
+
+	now := time.Now()
+	cert := &Certificate{
+		Primary: primary.Fingerprint(),
+		Subkey:  subkey.Fingerprint(),
+		Usage:   usage,
+		Created: now.Unix(),
+	}
+	if expires > 0 {
+		cert.Expires = now.Add(expires).Unix()
+	}
+
+	env, err := signature.New(CertificateType, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.Sign(primary)
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// ReadCertificate returns the unverified certificate in the key file in
+// path, or nil if the key isn't a subkey.
+func ReadCertificate(path string) (*signature.Envelope, *Certificate, error) {
+	fields, err := readKeyFields(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, ok := fields[certificateKey]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	env := &signature.Envelope{}
+	err = json.Unmarshal(raw, env)
+	if err != nil {
+		return nil, nil, errors.Errorf("%s: invalid certificate: %s", path, err)
+	}
+
+	if env.Type != CertificateType {
+		return nil, nil, errors.Errorf("%s: incompatible certificate type (%s vs %s)", path, env.Type,
+			CertificateType)
+	}
+
+	cert := &Certificate{}
+	err = env.Unmarshal(cert)
+	if err != nil {
+		return nil, nil, errors.Errorf("%s: invalid certificate: %s", path, err)
+	}
+
+	return env, cert, ValidateUsage(cert.Usage)
+}
+
+// WriteCertificate stores env in the key file in path.
+func WriteCertificate(path string, env *signature.Envelope) error {
+	return writeKeyField(path, certificateKey, env)
+}
+
+// ExpiresTime returns the expiry time, or the zero time if the certificate
+// doesn't expire.
+func (c *Certificate) ExpiresTime() time.Time {
+	if c.Expires == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.Expires, 0)
+}
+
+// Expired returns true if the certificate has expired.
+func (c *Certificate) Expired() bool {
+	return c.Expires != 0 && time.Now().After(c.ExpiresTime())
+}
+
+// Describe returns a description of the purpose of the subkey.
+func (c *Certificate) Describe() string {
+	if c.Usage == UsageSign {
+		return "signing subkey"
+	}
+	return "encryption subkey"
+}
+
+// ValidateUsage returns an error if usage isn't the purpose of a subkey.
+func ValidateUsage(usage string) error {
+	for _, elt := range Usages {
+		if usage == elt {
+			return nil
+		}
+	}
+	return errors.Errorf("invalid subkey usage: %s (must be one of %s)", usage, strings.Join(Usages, ", "))
+}
+
+// certify verifies the certificates of the subkeys in the keyring.  Each
+// certificate must be signed by a primary key in the keyring.  Subkeys
+// with an expired certificate or an unknown primary key are dropped.
+func (k *Keyring) certify() error {
+	primaries := []cryptor.PublicKey{}
+	for _, key := range k.Public {
+		if key.env == nil {
+			primaries = append(primaries, key.PublicKey)
+		}
+	}
+
+	keys := []*PublicKey{}
+	for _, key := range k.Public {
+		if key.env == nil {
+			keys = append(keys, key)
+			continue
+		}
+
+		cert := &Certificate{}
+		err := key.env.Unmarshal(cert)
+		if err != nil {
+			return err
+		}
+
+		primary := findPublicKey(primaries, cert.Primary)
+		if primary == nil {
+			log.Warnf("%s: ignoring subkey because its primary key isn't in the keyring", key.Path)
+			continue
+		}
+
+		_, err = key.env.Verify([]cryptor.PublicKey{primary})
+		if err != nil {
+			return errors.Errorf("%s: invalid certificate: %s", key.Path, err)
+		}
+
+		if cert.Subkey != key.Fingerprint() {
+			return errors.Errorf("%s: the certificate is for another key", key.Path)
+		}
+
+		if cert.Expired() {
+			log.Warnf("%s: ignoring subkey that expired at %s", key.Path,
+				cert.ExpiresTime().UTC().Format(time.RFC3339))
+			continue
+		}
+
+		key.Certificate = cert
+		key.PublicKey = &subkey{PublicKey: key.PublicKey, cert: cert}
+		keys = append(keys, key)
+	}
+
+	k.Public = keys
+	return nil
+}
+
+func findPublicKey(keys []cryptor.PublicKey, fpr string) cryptor.PublicKey {
+	for _, key := range keys {
+		if key.Fingerprint() == fpr {
+			return key
+		}
+	}
+	return nil
+}
+
+// subkey limits a public key to the purpose in its certificate.
+type subkey struct {
+	cryptor.PublicKey
+	cert *Certificate
+}
+
+func (s *subkey) Verify(message []byte, sig []byte) error {
+	if s.cert.Usage != UsageSign {
+		return cryptor.ErrWrongPurpose
+	}
+	return s.PublicKey.Verify(message, sig)
+}
+
+func (s *subkey) Encrypt(plaintext []byte) (string, error) {
+	if s.cert.Usage != UsageEncrypt {
+		return "", cryptor.ErrWrongPurpose
+	}
+	return s.PublicKey.Encrypt(plaintext)
+}
+
+// privateSubkey limits a private key to the purpose in its certificate.
+type privateSubkey struct {
+	cryptor.PrivateKey
+	cert *Certificate
+}
+
+func (s *privateSubkey) Sign(message []byte) ([]byte, error) {
+	if s.cert.Usage != UsageSign {
+		return nil, errors.Wrapf(cryptor.ErrWrongPurpose, "%s", s.cert.Describe())
+	}
+	return s.PrivateKey.Sign(message)
+}
+
+func (s *privateSubkey) Decrypt(ciphertext string) ([]byte, error) {
+	if s.cert.Usage != UsageEncrypt {
+		return nil, errors.Wrapf(cryptor.ErrWrongPurpose, "%s", s.cert.Describe())
+	}
+	return s.PrivateKey.Decrypt(ciphertext)
+}
+
+// describeSubkey returns the purpose and the primary key of a subkey.
+func (k *Keyring) describeSubkey(cert *Certificate) string {
+	for _, elt := range k.everything() {
+		if elt.Fingerprint() == cert.Primary {
+			return fmt.Sprintf("%s of %s (%s)", cert.Describe(), elt.Name, cert.Primary)
+		}
+	}
+	return fmt.Sprintf("%s of %s", cert.Describe(), cert.Primary)
+}
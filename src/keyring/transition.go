@@ -0,0 +1,288 @@
+package keyring
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/illikainen/bambi/src/metadata"
+	"github.com/illikainen/bambi/src/signature"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A transition statement links a key to the key that replaces it.  It's
+// signed by both keys so that it proves that the owner of the old key
+// holds the new key and vice versa.  The new public key is embedded in the
+// statement, so anyone who trusts the old key can start to trust the new
+// key without any other configuration.
+type Transition struct {
+	Old     string
+	New     string
+	Key     json.RawMessage
+	Created int64
+}
+
+// TransitionType is the envelope type for transition statements.
+var TransitionType = metadata.Name() + ".key-transition"
+
+// NewTransition returns a statement that newPub replaces old, signed by
+// old and by newPriv.
+func NewTransition(old cryptor.PrivateKey, newPub cryptor.PublicKey,
+	newPriv cryptor.PrivateKey) (*signature.Envelope, error) {
+	if newPub.Fingerprint() != newPriv.Fingerprint() {
+		return nil, errors.Errorf("the new public and private keys don't match")
+	}
+
+	if old.Fingerprint() == newPub.Fingerprint() {
+		return nil, errors.Errorf("a key can't replace itself")
+	}
+
+	key, err := json.Marshal(newPub)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := signature.New(TransitionType, &Transition{
+		Old:     old.Fingerprint(),
+		New:     newPub.Fingerprint(),
+		Key:     key,
+		Created: time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, priv := range []cryptor.PrivateKey{old, newPriv} {
+		err = env.Sign(priv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
+// transition adds the new keys from the transition statements in paths
+// for every old key in the keyring.  Statements may be chained, and the
+// order of the paths doesn't matter.
+func (k *Keyring) transition(paths []string) error {
+	type statement struct {
+		*Transition
+		env  *signature.Envelope
+		path string
+	}
+
+	pending := []*statement{}
+	for _, path := range paths {
+		path, err := iofs.Expand(path)
+		if err != nil {
+			return err
+		}
+
+		env, err := signature.Read(path, TransitionType)
+		if err != nil {
+			return err
+		}
+
+		stmt := &statement{Transition: &Transition{}, env: env, path: path}
+		err = env.Unmarshal(stmt.Transition)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, stmt)
+	}
+
+	for progress := true; progress; {
+		progress = false
+		remaining := []*statement{}
+
+		for _, stmt := range pending {
+			old := k.lookupKey(stmt.Old)
+			if old == nil {
+				remaining = append(remaining, stmt)
+				continue
+			}
+
+			err := k.addSuccessor(old, stmt.Transition, stmt.env, stmt.path)
+			if err != nil {
+				return errors.Errorf("%s: %s", stmt.path, err)
+			}
+			progress = true
+		}
+
+		pending = remaining
+	}
+
+	for _, stmt := range pending {
+		log.Debugf("%s: ignoring transition from a key that isn't in the keyring", stmt.path)
+	}
+	return nil
+}
+
+func (k *Keyring) addSuccessor(old *PublicKey, stmt *Transition, env *signature.Envelope, path string) error {
+	key := &asymmetric.PublicKeyContainer{}
+	err := key.UnmarshalJSON(stmt.Key)
+	if err != nil {
+		return err
+	}
+
+	if key.Type != cryptor.PublicKeyType || key.Fingerprint() != stmt.New {
+		return errors.Errorf("the key in the transition doesn't match its fingerprint")
+	}
+
+	signers, err := env.Verify([]cryptor.PublicKey{old.PublicKey, key})
+	if err != nil {
+		return err
+	}
+	if len(signers) != 2 {
+		return errors.Errorf("the transition must be signed by both the old and the new key")
+	}
+
+	log.Debugf("%s: %s is replaced by %s", path, old.Fingerprint(), stmt.New)
+
+	// The new key may already be in the keyring under another name.
+	successor := k.lookupKey(stmt.New)
+	if successor == nil {
+		successor = &PublicKey{PublicKey: key, Name: old.Name, Path: path}
+		k.Public = append(k.Public, successor)
+	}
+	successor.Predecessor = old.Fingerprint()
+	return nil
+}
+
+// successor returns the newest key that replaces key through one or more
+// transitions, or key itself if it hasn't been replaced.
+func (k *Keyring) successor(key *PublicKey) *PublicKey {
+	seen := map[string]bool{}
+	for !seen[key.Fingerprint()] {
+		seen[key.Fingerprint()] = true
+
+		for _, elt := range k.everything() {
+			if elt.Predecessor == key.Fingerprint() {
+				key = elt
+				break
+			}
+		}
+	}
+	return key
+}
+
+// successors returns the keys that replace the key with the fingerprint
+// fpr, directly or through a chain of transitions.
+func (k *Keyring) successors(fpr string) []*PublicKey {
+	keys := []*PublicKey{}
+	seen := map[string]bool{fpr: true}
+	queue := []string{fpr}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, elt := range k.everything() {
+			if elt.Predecessor == current && !seen[elt.Fingerprint()] {
+				seen[elt.Fingerprint()] = true
+				keys = append(keys, elt)
+				queue = append(queue, elt.Fingerprint())
+			}
+		}
+	}
+	return keys
+}
+
+// Identity returns the fingerprint of the key that the key with the
+// fingerprint fpr belongs to: the primary key of a subkey, and the first
+// key in a chain of transitions.  Keys that aren't in the keyring are
+// their own identity.
+func (k *Keyring) Identity(fpr string) string {
+	seen := map[string]bool{}
+	for !seen[fpr] {
+		seen[fpr] = true
+
+		var key *PublicKey
+		for _, elt := range k.everything() {
+			if elt.Fingerprint() == fpr {
+				key = elt
+				break
+			}
+		}
+
+		switch {
+		case key == nil:
+			return fpr
+		case key.Certificate != nil:
+			fpr = key.Certificate.Primary
+		case key.Predecessor != "":
+			fpr = key.Predecessor
+		}
+	}
+	return fpr
+}
+
+// Identities returns the identity of every key in the keyring.
+func (k *Keyring) Identities() map[string]string {
+	identities := map[string]string{}
+	for _, key := range k.everything() {
+		identities[key.Fingerprint()] = k.Identity(key.Fingerprint())
+	}
+	return identities
+}
+
+// lineage returns the fingerprints of the keys that the key with the
+// fingerprint fpr belongs to, in the order that Identity() visits them.
+func (k *Keyring) lineage(fpr string) []string {
+	fprs := []string{}
+	seen := map[string]bool{fpr: true}
+	for {
+		var key *PublicKey
+		for _, elt := range k.everything() {
+			if elt.Fingerprint() == fpr {
+				key = elt
+				break
+			}
+		}
+
+		switch {
+		case key == nil:
+			return fprs
+		case key.Certificate != nil:
+			fpr = key.Certificate.Primary
+		case key.Predecessor != "":
+			fpr = key.Predecessor
+		default:
+			return fprs
+		}
+
+		if seen[fpr] {
+			return fprs
+		}
+		seen[fpr] = true
+		fprs = append(fprs, fpr)
+	}
+}
+
+// link attaches the lineage of every subkey and successor in the keyring
+// to its public key, so that it can be refused if its primary key or any
+// of its predecessors is revoked.
+func (k *Keyring) link() {
+	for _, key := range k.Public {
+		lineage := k.lineage(key.Fingerprint())
+		if len(lineage) > 0 {
+			key.PublicKey = &linkedKey{PublicKey: key.PublicKey, lineage: lineage}
+		}
+	}
+}
+
+// linkedKey is a public key that belongs to other keys.
+type linkedKey struct {
+	cryptor.PublicKey
+	lineage []string
+}
+
+// Lineage returns the fingerprints of the primary key and the predecessors
+// that the key belongs to, directly or through other keys.
+func (l *linkedKey) Lineage() []string {
+	return l.lineage
+}
@@ -0,0 +1,56 @@
+package keyring
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/illikainen/go-cryptor/src/cryptor"
+)
+
+// fakeKey is a public key that only has a fingerprint.
+type fakeKey struct {
+	cryptor.PublicKey
+	fpr string
+}
+
+func (f *fakeKey) Fingerprint() string {
+	return f.fpr
+}
+
+func lineageOf(key *PublicKey) []string {
+	linked, ok := key.PublicKey.(interface{ Lineage() []string })
+	if !ok {
+		return nil
+	}
+	return linked.Lineage()
+}
+
+func TestLink(t *testing.T) {
+	keys := &Keyring{Public: []*PublicKey{
+		{PublicKey: &fakeKey{fpr: "sub"}, Certificate: &Certificate{Primary: "c", Subkey: "sub"}},
+		{PublicKey: &fakeKey{fpr: "c"}, Predecessor: "b"},
+		{PublicKey: &fakeKey{fpr: "b"}, Predecessor: "a"},
+		{PublicKey: &fakeKey{fpr: "a"}},
+		{PublicKey: &fakeKey{fpr: "other"}},
+		{PublicKey: &fakeKey{fpr: "x"}, Predecessor: "y"},
+		{PublicKey: &fakeKey{fpr: "y"}, Predecessor: "x"},
+	}}
+	keys.link()
+
+	tests := map[string][]string{
+		"sub":   {"c", "b", "a"},
+		"c":     {"b", "a"},
+		"b":     {"a"},
+		"a":     nil,
+		"other": nil,
+		"x":     {"y"},
+		"y":     {"x"},
+	}
+
+	for _, key := range keys.Public {
+		want := tests[key.Fingerprint()]
+		if got := lineageOf(key); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got lineage %v, want %v", key.Fingerprint(), got, want)
+		}
+	}
+}
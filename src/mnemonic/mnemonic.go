@@ -0,0 +1,113 @@
+package mnemonic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Mnemonics are encoded as in BIP39 with its English word list.  Each
+// word encodes 11 bits, and the entropy is followed by the first
+// len(entropy)/4 bits of its SHA-256 digest as a checksum.
+const (
+	EntropySize = 32
+	bitsPerWord = 11
+	seedRounds  = 2048
+	seedSize    = 64
+)
+
+//go:embed english.txt
+var english string
+
+var wordlist = strings.Fields(english)
+
+var wordIndex = func() map[string]int {
+	index := map[string]int{}
+	for i, word := range wordlist {
+		index[word] = i
+	}
+	return index
+}()
+
+// Generate returns a mnemonic for EntropySize random bytes.
+func Generate() (string, error) {
+	entropy := make([]byte, EntropySize)
+	_, err := rand.Read(entropy)
+	if err != nil {
+		return "", err
+	}
+	return Encode(entropy)
+}
+
+// Encode returns the mnemonic for entropy.
+func Encode(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", errors.Errorf("invalid entropy size: %d", len(entropy))
+	}
+
+	csBits := len(entropy) / 4
+	digest := sha256.Sum256(entropy)
+
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, uint(csBits))
+	value.Or(value, big.NewInt(int64(digest[0]>>(8-csBits))))
+
+	count := (len(entropy)*8 + csBits) / bitsPerWord
+	words := make([]string, count)
+	mask := big.NewInt(1<<bitsPerWord - 1)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = wordlist[new(big.Int).And(value, mask).Int64()]
+		value.Rsh(value, bitsPerWord)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// Decode returns the entropy in a mnemonic.  An error is returned if a
+// word is unknown or if the checksum doesn't match.
+func Decode(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, errors.Errorf("invalid number of words: %d", len(words))
+	}
+
+	value := new(big.Int)
+	for i, word := range words {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, errors.Errorf("word %d is invalid: %s", i+1, word)
+		}
+		value.Lsh(value, bitsPerWord)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+
+	csBits := len(words) * bitsPerWord / 33
+	checksum := new(big.Int).And(value, big.NewInt(1<<csBits-1)).Int64()
+	value.Rsh(value, uint(csBits))
+
+	entropy := value.FillBytes(make([]byte, csBits*4))
+	digest := sha256.Sum256(entropy)
+	if int64(digest[0]>>(8-csBits)) != checksum {
+		return nil, errors.Errorf("invalid checksum; the words are misspelled or in the wrong order")
+	}
+
+	return entropy, nil
+}
+
+// Seed validates a mnemonic and derives a seed from it as in BIP39,
+// without a passphrase.
+func Seed(mnemonic string) ([]byte, error) {
+	_, err := Decode(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"), seedRounds, seedSize, sha512.New), nil
+}
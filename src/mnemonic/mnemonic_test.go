@@ -0,0 +1,196 @@
+package mnemonic
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Entropy and mnemonics from the BIP39 reference test vectors.
+var vectors = []struct {
+	entropy  string
+	mnemonic string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+	},
+	{
+		"80808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+	},
+	{
+		"ffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+	},
+	{
+		"000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon agent",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo when",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth " +
+			"useful legal winner thank year wave sausage worth title",
+	},
+	{
+		"8080808080808080808080808080808080808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor " +
+			"acoustic avoid letter advice cage absurd amount doctor acoustic bless",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+	},
+	{
+		"9e885d952ad362caeb4efe34a8e91bd2",
+		"ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+	},
+	{
+		"68a79eaca2324873eacc50cb9c6eca8cc68ea5d936f98787c60c7ebc74e6ce7c",
+		"hamster diagram private dutch cause delay private meat slide toddler razor book happy fancy " +
+			"gospel tennis maple dilemma loan word shrug inflict delay length",
+	},
+}
+
+func TestWordlist(t *testing.T) {
+	if len(wordlist) != 1<<bitsPerWord || len(wordIndex) != len(wordlist) {
+		t.Fatalf("invalid word list: %d words, %d unique", len(wordlist), len(wordIndex))
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, vector := range vectors {
+		entropy, err := hex.DecodeString(vector.entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mnemonic, err := Encode(entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != vector.mnemonic {
+			t.Errorf("Encode(%s) = %q, want %q", vector.entropy, mnemonic, vector.mnemonic)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, vector := range vectors {
+		entropy, err := Decode(vector.mnemonic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(entropy) != vector.entropy {
+			t.Errorf("Decode(%q) = %x, want %s", vector.mnemonic, entropy, vector.entropy)
+		}
+
+		// Case and whitespace don't matter.
+		entropy, err = Decode("  " + strings.ToUpper(strings.ReplaceAll(vector.mnemonic, " ", "\n ")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(entropy) != vector.entropy {
+			t.Errorf("Decode(%q) = %x, want %s", vector.mnemonic, entropy, vector.entropy)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abou",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo",
+		"legal winner thank year wave sausage worth useful legal winner yellow thank",
+	}
+
+	for _, test := range tests {
+		_, err := Decode(test)
+		if err == nil {
+			t.Errorf("Decode(%q) succeeded", test)
+		}
+		_, err = Seed(test)
+		if err == nil {
+			t.Errorf("Seed(%q) succeeded", test)
+		}
+	}
+}
+
+func TestEncodeInvalid(t *testing.T) {
+	for _, size := range []int{0, 4, 15, 17, 36} {
+		_, err := Encode(make([]byte, size))
+		if err == nil {
+			t.Errorf("Encode with %d bytes succeeded", size)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	// BIP39 with an empty passphrase.
+	want := "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc1" +
+		"9a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4"
+
+	seed, err := Seed(vectors[0].mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(seed) != want {
+		t.Fatalf("Seed(%q) = %x, want %s", vectors[0].mnemonic, seed, want)
+	}
+
+	seed, err = Seed(strings.ToUpper(vectors[0].mnemonic) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(seed) != want {
+		t.Fatalf("the seed depends on case or whitespace: %x", seed)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	mnemonic, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entropy, err := Decode(mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entropy) != EntropySize {
+		t.Fatalf("got %d bytes of entropy, want %d", len(entropy), EntropySize)
+	}
+
+	other, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mnemonic == other {
+		t.Fatalf("Generate returned the same mnemonic twice")
+	}
+
+	again, err := Encode(entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != mnemonic {
+		t.Fatalf("the mnemonic doesn't round-trip")
+	}
+}
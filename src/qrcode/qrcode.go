@@ -0,0 +1,528 @@
+package qrcode
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Codes are encoded in byte mode with error correction level M, which is
+// enough for fingerprints and other short strings.  Only versions 1-10 are
+// supported.
+const (
+	MaxVersion = 10
+	eccLevelM  = 0
+	quietZone  = 4
+)
+
+type blockSpec struct {
+	ecLen  int
+	groups [][2]int // number of blocks and data codewords per block
+}
+
+var blockSpecs = [MaxVersion + 1]blockSpec{
+	1:  {10, [][2]int{{1, 16}}},
+	2:  {16, [][2]int{{1, 28}}},
+	3:  {26, [][2]int{{1, 44}}},
+	4:  {18, [][2]int{{2, 32}}},
+	5:  {24, [][2]int{{2, 43}}},
+	6:  {16, [][2]int{{4, 27}}},
+	7:  {18, [][2]int{{4, 31}}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+var alignment = [MaxVersion + 1][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// Code is a QR code where Modules[y][x] is true for dark modules.
+type Code struct {
+	Version int
+	Size    int
+	Modules [][]bool
+
+	function [][]bool
+}
+
+// Encode returns the smallest QR code for data.
+func Encode(data []byte) (*Code, error) {
+	for version := 1; version <= MaxVersion; version++ {
+		if len(data) <= capacity(version) {
+			return encode(data, version), nil
+		}
+	}
+	return nil, errors.Errorf("too much data for a QR code: %d bytes", len(data))
+}
+
+// Lines renders the code in ASCII with two characters for every module
+// so that the modules are roughly square.  Dark modules are drawn and light
+// modules are left blank.
+func (c *Code) Lines() []string {
+	lines := []string{}
+	for y := -quietZone; y < c.Size+quietZone; y++ {
+		line := ""
+		for x := -quietZone; x < c.Size+quietZone; x++ {
+			if x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.Modules[y][x] {
+				line += "##"
+			} else {
+				line += "  "
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func capacity(version int) int {
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	return (dataCodewords(version)*8 - 4 - countBits) / 8
+}
+
+func dataCodewords(version int) int {
+	n := 0
+	for _, group := range blockSpecs[version].groups {
+		n += group[0] * group[1]
+	}
+	return n
+}
+
+func encode(data []byte, version int) *Code {
+	size := version*4 + 17
+	c := &Code{Version: version, Size: size}
+	c.Modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.Modules {
+		c.Modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(dataBits(data, version), version))
+
+	best := -1
+	bestPenalty := 0
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		penalty := c.penalty()
+		if best < 0 || penalty < bestPenalty {
+			best = mask
+			bestPenalty = penalty
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c
+}
+
+// dataBits returns the data codewords for data in byte mode, padded to the
+// capacity of version.
+func dataBits(data []byte, version int) []byte {
+	bits := []bool{}
+	appendBits := func(value int, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 == 1)
+		}
+	}
+
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+
+	appendBits(0x4, 4)
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	total := dataCodewords(version) * 8
+	for i := 0; i < 4 && len(bits) < total; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	for pad := byte(0xec); len(codewords) < total/8; pad ^= 0xec ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// interleave splits data into blocks, adds the error correction codewords
+// of each block and interleaves the blocks.
+func interleave(data []byte, version int) []byte {
+	spec := blockSpecs[version]
+	divisor := rsDivisor(spec.ecLen)
+
+	blocks := [][]byte{}
+	ecBlocks := [][]byte{}
+	for _, group := range spec.groups {
+		for i := 0; i < group[0]; i++ {
+			block := data[:group[1]]
+			data = data[group[1]:]
+			blocks = append(blocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+		}
+	}
+
+	result := []byte{}
+	for i := 0; ; i++ {
+		done := true
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	for i := 0; i < spec.ecLen; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+func (c *Code) set(x int, y int, dark bool) {
+	c.Modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	pos := alignment[c.Version]
+	for i, x := range pos {
+		for j, y := range pos {
+			// Skip the positions that overlap with the finder patterns.
+			last := len(pos) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format bits; they're drawn after the mask is chosen.
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+func (c *Code) drawFinder(cx int, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x := cx + dx
+			y := cy + dy
+			if x >= 0 && y >= 0 && x < c.Size && y < c.Size {
+				dist := chebyshev(dx, dy)
+				c.set(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+func (c *Code) drawAlignment(cx int, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(cx+dx, cy+dy, chebyshev(dx, dy) != 1)
+		}
+	}
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool {
+		return (bits>>i)&1 == 1
+	}
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// formatBits returns the error correction level and mask with their BCH
+// code, masked with 0x5412.
+func formatBits(mask int) int {
+	data := eccLevelM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a := c.Size - 11 + i%3
+		b := i / 3
+		c.set(a, b, dark)
+		c.set(b, a, dark)
+	}
+}
+
+// versionBits returns version with its BCH code.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	return version<<12 | rem
+}
+
+// drawCodewords places the codewords in the zigzag pattern from the
+// bottom right corner, skipping function modules.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+
+				if !c.function[y][x] && i < len(data)*8 {
+					c.Modules[y][x] = (data[i>>3]>>(7-(i&7)))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by mask.  Applying the same
+// mask twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			invert := false
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			if invert && !c.function[y][x] {
+				c.Modules[y][x] = !c.Modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the code with the rules in the QR code specification; the
+// mask with the lowest score is used.
+func (c *Code) penalty() int {
+	result := 0
+	finder := []bool{true, false, true, true, true, false, true}
+
+	for y := 0; y < c.Size; y++ {
+		row := c.Modules[y]
+		col := make([]bool, c.Size)
+		for x := 0; x < c.Size; x++ {
+			col[x] = c.Modules[x][y]
+		}
+
+		for _, line := range [][]bool{row, col} {
+			result += runPenalty(line)
+			result += 40 * finderPenalty(line, finder)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				color := c.Modules[y][x]
+				if color == c.Modules[y][x+1] && color == c.Modules[y+1][x] &&
+					color == c.Modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	// Ten points for every 5% that the dark modules deviate from 50%.
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+	return result
+}
+
+func runPenalty(line []bool) int {
+	result := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += run - 2
+		}
+		run = 1
+	}
+	return result
+}
+
+// finderPenalty counts the patterns that look like finder patterns with
+// four light modules on either side.  Modules outside the code are light.
+func finderPenalty(line []bool, finder []bool) int {
+	at := func(i int) bool {
+		return i >= 0 && i < len(line) && line[i]
+	}
+
+	count := 0
+	for i := -4; i < len(line); i++ {
+		match := true
+		for j, dark := range finder {
+			if at(i+j) != dark {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+
+		before := true
+		after := true
+		for j := 1; j <= 4; j++ {
+			before = before && !at(i-j)
+			after = after && !at(i+len(finder)-1+j)
+		}
+		if before || after {
+			count++
+		}
+	}
+	return count
+}
+
+// rsDivisor returns the generator polynomial of degree n for Reed-Solomon
+// codes over GF(2^8) with the polynomial 0x11d.
+func rsDivisor(n int) []byte {
+	result := make([]byte, n)
+	result[n-1] = 1
+
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coeff := range divisor {
+			result[i] ^= gfMul(coeff, factor)
+		}
+	}
+	return result
+}
+
+func gfMul(x byte, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// chebyshev returns the distance from the center of a pattern.
+func chebyshev(dx int, dy int) int {
+	if abs(dx) > abs(dy) {
+		return abs(dx)
+	}
+	return abs(dy)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
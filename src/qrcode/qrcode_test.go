@@ -0,0 +1,250 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Reference symbols for level M in byte mode.  They're identical to the
+// symbols from two other encoders (Kazuhiko Arase's QR code generator and
+// github.com/skip2/go-qrcode) with the same mask.
+var (
+	helloSymbol = []string{
+		"#######..#.##.#######",
+		"#.....#.##..#.#.....#",
+		"#.###.#..#..#.#.###.#",
+		"#.###.#...##..#.###.#",
+		"#.###.#.#..##.#.###.#",
+		"#.....#....#..#.....#",
+		"#######.#.#.#.#######",
+		"..........#..........",
+		"#.#.#.#..#..#...#..#.",
+		"#.##...###.#....#..##",
+		".#..####.###.#.######",
+		"####.#.######..#...#.",
+		".######.#.##....#....",
+		"........##.#..###.###",
+		"#######..#..##..#.###",
+		"#.....#....#...#...#.",
+		"#.###.#.##.###.#...#.",
+		"#.###.#..#.###.##.##.",
+		"#.###.#.#..##...#.#.#",
+		"#.....#..#.#....#..#.",
+		"#######.####...#...##",
+	}
+
+	// Version 7 with the version information and alignment patterns.
+	fingerprintSymbol = []string{
+		"#######.###.#.#.####.##.##.#.####...#.#######",
+		"#.....#.###...#..#.#.#....##.###...#..#.....#",
+		"#.###.#..###......#.#.##..##.####..#..#.###.#",
+		"#.###.#.#.###.##.###..#.#.#........##.#.###.#",
+		"#.###.#......#...#########......#####.#.###.#",
+		"#.....#..##.#.##...##...##..#.#..#....#.....#",
+		"#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#######",
+		"........#.#.#####...#...#....#.#.###.........",
+		"#.##.###.#.#...#....##########.#.#..#.#..#.##",
+		"...#.#.##.###.##.####...##.####.#...##..#.###",
+		"##.#..###.#.#.##.##.###.##.#..###.#....#..###",
+		"....##..##.##...#.#.#.#.##.##..##...##...#..#",
+		"..###.#.#.#..##..##.#.##...#..##.#..###......",
+		"#.##.#.##..#.#..#.##....####.....#....##.....",
+		".##...##.#.#....#.######.##.####...####..#...",
+		"...##..#..#.##.#####.#..#...##...###..#####.#",
+		"#.##.###.#####.#....##..#..#.#.#####.###.#..#",
+		".#.##..#.#.#.##...##...##..#.#...###.#..#.##.",
+		"#..#..#.#.####.##.#...#####..##..#...##..###.",
+		"..####.##.##.#..#..###....##...#.....#.###...",
+		"..#.#####..#.#..##.######..##.....#######.#..",
+		"....#...#.#.#.#....##...#..##.#.#..##...#..##",
+		"##.##.#.##.##...#.#.#.#.#####.##.##.#.#.#...#",
+		"##..#...#..#.#.##.#.#...#..#.#.#....#...##.#.",
+		"#..##########.#..#..######....##.########.##.",
+		"..###...##.#....##.#...#######..##.##....####",
+		".#..#.######.#.....#...###..#####......#.....",
+		"..#.#...##.##..##.##....##.#.##..#.#..#...##.",
+		".....####.#..#..#..#...#.#########.#...##.##.",
+		"##..#....###..#..###.#..#......##.##..#.#..##",
+		"..###.###.##..##....#.####..##..#.#.##.#...#.",
+		"...#.#.#.####..##.#.##.#..#..#.#.##.#.#.#..##",
+		"#.#.####.#.##.#..###..##..######.##...#..#..#",
+		"..##.#.....####.####..#.##...###...#.##...#.#",
+		"....#.####.#####.....###..###..##..####.##.##",
+		".####......##.#...###..#..#.##.##.#.#..###..#",
+		"#..##.####.##..#..#######.#..#.#.##.#####..##",
+		"........##...##..##.#...##.....###.##...#.###",
+		"#######.##.#.##.##.##.#.##.#..###...#.#.###..",
+		"#.....#.###.#...##.##...##.#.##....##...###.#",
+		"#.###.#.....##.#..#.########.#.###..#########",
+		"#.###.#.#..###########.....###...##.#...##.##",
+		"#.###.#.###....##.#..#.#..#....###.###....##.",
+		"#.....#..####.....#...##.##....##...#.##.#..#",
+		"#######.###.#..#.....#.#.#..###..#.#..#..##..",
+	}
+)
+
+const fingerprintData = "SyOh7kLVOjeE1OlSUCvJuEh1KKjnZvT3h473/VoHa3ZBnd7lNPpeTIy92JOYofEBYDi52i+" +
+	"MMHF1t4mGtBsLLw== bambi fingerprint v7"
+
+func TestRSDivisor(t *testing.T) {
+	// ISO/IEC 18004 annex A, with the coefficients as powers of alpha.
+	tests := []struct {
+		degree int
+		want   []byte
+	}{
+		// a^87 a^229 a^146 a^149 a^238 a^102 a^21
+		{7, []byte{0x7f, 0x7a, 0x9a, 0xa4, 0x0b, 0x44, 0x75}},
+		// a^251 a^67 a^46 a^61 a^118 a^70 a^64 a^94 a^32 a^45
+		{10, []byte{0xd8, 0xc2, 0x9f, 0x6f, 0xc7, 0x5e, 0x5f, 0x71, 0x9d, 0xc1}},
+	}
+
+	for _, test := range tests {
+		if got := rsDivisor(test.degree); !bytes.Equal(got, test.want) {
+			t.Errorf("rsDivisor(%d) = %#v, want %#v", test.degree, got, test.want)
+		}
+	}
+}
+
+func TestRSRemainder(t *testing.T) {
+	// Version 1-M: "01234567" in numeric mode from ISO/IEC 18004 annex I,
+	// and "HELLO WORLD" in alphanumeric mode.
+	tests := []struct {
+		data []byte
+		want []byte
+	}{
+		{
+			[]byte{16, 32, 12, 86, 97, 128, 236, 17, 236, 17, 236, 17, 236, 17, 236, 17},
+			[]byte{165, 36, 212, 193, 237, 54, 199, 135, 44, 85},
+		},
+		{
+			[]byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+			[]byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		},
+	}
+
+	for _, test := range tests {
+		if got := rsRemainder(test.data, rsDivisor(10)); !bytes.Equal(got, test.want) {
+			t.Errorf("rsRemainder(%v) = %v, want %v", test.data, got, test.want)
+		}
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// Level M with masks 0-7 after the 0x5412 mask.
+	want := []int{
+		0b101010000010010,
+		0b101000100100101,
+		0b101111001111100,
+		0b101101101001011,
+		0b100010111111001,
+		0b100000011001110,
+		0b100111110010111,
+		0b100101010100000,
+	}
+
+	for mask, bits := range want {
+		if got := formatBits(mask); got != bits {
+			t.Errorf("formatBits(%d) = %015b, want %015b", mask, got, bits)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	want := map[int]int{
+		7:  0x07c94,
+		8:  0x085bc,
+		9:  0x09a99,
+		10: 0x0a4d3,
+	}
+
+	for version, bits := range want {
+		if got := versionBits(version); got != bits {
+			t.Errorf("versionBits(%d) = %#05x, want %#05x", version, got, bits)
+		}
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	// Byte mode capacities for level M.
+	want := []int{1: 14, 26, 42, 62, 84, 106, 122, 152, 180, 213}
+
+	for version := 1; version <= MaxVersion; version++ {
+		if got := capacity(version); got != want[version] {
+			t.Errorf("capacity(%d) = %d, want %d", version, got, want[version])
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		data    string
+		version int
+		want    []string
+	}{
+		{"hello, world", 1, helloSymbol},
+		{fingerprintData, 7, fingerprintSymbol},
+	}
+
+	for _, test := range tests {
+		c, err := Encode([]byte(test.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Version != test.version || c.Size != len(test.want) {
+			t.Fatalf("%q: got version %d and size %d", test.data, c.Version, c.Size)
+		}
+
+		for y, row := range c.Modules {
+			got := ""
+			for _, dark := range row {
+				if dark {
+					got += "#"
+				} else {
+					got += "."
+				}
+			}
+			if got != test.want[y] {
+				t.Errorf("%q: row %d is %s, want %s", test.data, y, got, test.want[y])
+			}
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	_, err := Encode(bytes.Repeat([]byte("x"), capacity(MaxVersion)+1))
+	if err == nil {
+		t.Fatal("Encode succeeded")
+	}
+
+	c, err := Encode(bytes.Repeat([]byte("x"), capacity(MaxVersion)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != MaxVersion {
+		t.Fatalf("got version %d", c.Version)
+	}
+}
+
+func TestLines(t *testing.T) {
+	c, err := Encode([]byte("hello, world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := c.Lines()
+	size := c.Size + 2*quietZone
+	if len(lines) != size {
+		t.Fatalf("got %d lines, want %d", len(lines), size)
+	}
+
+	blank := strings.Repeat(" ", 2*size)
+	if lines[0] != blank || lines[size-1] != blank {
+		t.Fatal("no quiet zone")
+	}
+
+	want := strings.Repeat("  ", quietZone) + strings.Repeat("##", 7) + "  "
+	if !strings.HasPrefix(lines[quietZone], want) {
+		t.Fatalf("got %q, want a finder pattern", lines[quietZone])
+	}
+}
@@ -0,0 +1,133 @@
+package shamir
+
+import (
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+)
+
+// Secrets are split byte by byte with Shamir's secret sharing over
+// GF(2^8) with the AES polynomial.  Each share is the x-coordinate of the
+// share followed by one y-coordinate for each byte in the secret.
+const (
+	MaxShares = 255
+	MinShares = 2
+)
+
+type Share struct {
+	X    byte
+	Data []byte
+}
+
+// Split splits secret into n shares of which any threshold are needed to
+// recover it.
+func Split(secret []byte, n int, threshold int) ([]*Share, error) {
+	if threshold < MinShares || threshold > n || n > MaxShares {
+		return nil, errors.Errorf("invalid number of shares: %d of %d", threshold, n)
+	}
+
+	if len(secret) == 0 {
+		return nil, errors.Errorf("the secret can't be empty")
+	}
+
+	shares := make([]*Share, n)
+	for i := range shares {
+		shares[i] = &Share{X: byte(i + 1), Data: make([]byte, len(secret))}
+	}
+
+	coeffs := make([]byte, threshold)
+	defer wipe(coeffs)
+
+	for i, b := range secret {
+		coeffs[0] = b
+		_, err := rand.Read(coeffs[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, share := range shares {
+			share.Data[i] = evaluate(coeffs, share.X)
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine recovers the secret from shares.  A wrong secret is returned if
+// there are fewer shares than the threshold, so the caller must verify it.
+func Combine(shares []*Share) ([]byte, error) {
+	if len(shares) < MinShares {
+		return nil, errors.Errorf("at least %d shares are required", MinShares)
+	}
+
+	size := len(shares[0].Data)
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if share.X == 0 || seen[share.X] {
+			return nil, errors.Errorf("invalid or duplicate share: %d", share.X)
+		}
+		seen[share.X] = true
+
+		if len(share.Data) != size {
+			return nil, errors.Errorf("the shares have different sizes")
+		}
+	}
+
+	secret := make([]byte, size)
+	for i, share := range shares {
+		// Lagrange basis polynomial for this share, evaluated at zero.
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = mul(basis, div(other.X, add(other.X, share.X)))
+			}
+		}
+
+		for k := range secret {
+			secret[k] = add(secret[k], mul(share.Data[k], basis))
+		}
+	}
+
+	return secret, nil
+}
+
+// evaluate evaluates the polynomial with the coefficients in coeffs at x
+// with Horner's method.
+func evaluate(coeffs []byte, x byte) byte {
+	result := byte(0)
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		result = add(mul(result, x), coeffs[i])
+	}
+	return result
+}
+
+func add(a byte, b byte) byte {
+	return a ^ b
+}
+
+// mul multiplies without lookup tables or branches on secret data.
+func mul(a byte, b byte) byte {
+	result := byte(0)
+	for i := 0; i < 8; i++ {
+		result ^= a & -(b & 1)
+		carry := -(a >> 7)
+		a = (a << 1) ^ (0x1b & carry)
+		b >>= 1
+	}
+	return result
+}
+
+// div divides a by b.  The inverse of b is b^254.
+func div(a byte, b byte) byte {
+	inverse := byte(1)
+	for i := 0; i < 254; i++ {
+		inverse = mul(inverse, b)
+	}
+	return mul(a, inverse)
+}
+
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
@@ -0,0 +1,165 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMul(t *testing.T) {
+	// FIPS-197 section 4.2.
+	tests := []struct {
+		a, b, want byte
+	}{
+		{0x57, 0x83, 0xc1},
+		{0x57, 0x13, 0xfe},
+		{0x57, 0x02, 0xae},
+		{0x57, 0x04, 0x47},
+		{0x57, 0x08, 0x8e},
+		{0x57, 0x10, 0x07},
+		{0x53, 0xca, 0x01},
+		{0x00, 0xff, 0x00},
+		{0x01, 0xff, 0xff},
+	}
+
+	for _, test := range tests {
+		if got := mul(test.a, test.b); got != test.want {
+			t.Errorf("mul(%#02x, %#02x) = %#02x, want %#02x", test.a, test.b, got, test.want)
+		}
+		if got := mul(test.b, test.a); got != test.want {
+			t.Errorf("mul(%#02x, %#02x) = %#02x, want %#02x", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	for b := 1; b < 256; b++ {
+		if got := mul(byte(b), div(1, byte(b))); got != 1 {
+			t.Fatalf("%#02x * 1/%#02x = %#02x, want 1", b, b, got)
+		}
+
+		for a := 0; a < 256; a++ {
+			if got := div(mul(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatalf("%#02x * %#02x / %#02x = %#02x", a, b, b, got)
+			}
+		}
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	for n := MinShares; n <= 6; n++ {
+		for threshold := MinShares; threshold <= n; threshold++ {
+			shares, err := Split(secret, n, threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shares) != n {
+				t.Fatalf("%d of %d: got %d shares", threshold, n, len(shares))
+			}
+
+			for _, subset := range subsets(shares, threshold) {
+				got, err := Combine(subset)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, secret) {
+					t.Fatalf("%d of %d: wrong secret from %v", threshold, n, xs(subset))
+				}
+			}
+
+			// Any superset of the threshold works too.
+			got, err := Combine(shares)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, secret) {
+				t.Fatalf("%d of %d: wrong secret from all shares", threshold, n)
+			}
+
+			if threshold > MinShares {
+				for _, subset := range subsets(shares, threshold-1) {
+					got, err := Combine(subset)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if bytes.Equal(got, secret) {
+						t.Fatalf("%d of %d: recovered from %v", threshold, n, xs(subset))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	tests := []struct {
+		secret    []byte
+		n         int
+		threshold int
+	}{
+		{[]byte("x"), 3, 1},
+		{[]byte("x"), 3, 0},
+		{[]byte("x"), 2, 3},
+		{[]byte("x"), MaxShares + 1, 2},
+		{nil, 3, 2},
+		{[]byte{}, 3, 2},
+	}
+
+	for _, test := range tests {
+		_, err := Split(test.secret, test.n, test.threshold)
+		if err == nil {
+			t.Errorf("Split(%q, %d, %d) succeeded", test.secret, test.n, test.threshold)
+		}
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		shares []*Share
+	}{
+		{"none", nil},
+		{"one", shares[:1]},
+		{"duplicate", []*Share{shares[0], shares[0]}},
+		{"duplicate x", []*Share{shares[0], {X: shares[0].X, Data: shares[1].Data}}},
+		{"zero x", []*Share{shares[0], {X: 0, Data: shares[1].Data}}},
+		{"size", []*Share{shares[0], {X: shares[1].X, Data: shares[1].Data[1:]}}},
+	}
+
+	for _, test := range tests {
+		_, err := Combine(test.shares)
+		if err == nil {
+			t.Errorf("%s: Combine succeeded", test.name)
+		}
+	}
+}
+
+// subsets returns every k-subset of shares.
+func subsets(shares []*Share, k int) [][]*Share {
+	if k == 0 {
+		return [][]*Share{{}}
+	}
+
+	var result [][]*Share
+	for i := 0; i <= len(shares)-k; i++ {
+		for _, rest := range subsets(shares[i+1:], k-1) {
+			subset := append([]*Share{shares[i]}, rest...)
+			result = append(result, subset)
+		}
+	}
+	return result
+}
+
+func xs(shares []*Share) []byte {
+	var result []byte
+	for _, share := range shares {
+		result = append(result, share.X)
+	}
+	return result
+}
@@ -0,0 +1,169 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"github.com/illikainen/go-cryptor/src/asymmetric"
+	"github.com/illikainen/go-cryptor/src/cryptor"
+	"github.com/illikainen/go-utils/src/iofs"
+	"github.com/illikainen/go-utils/src/stringx"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Envelope is a payload with one or more signatures.  The type is signed
+// together with the payload so that an envelope can't be mistaken for an
+// envelope of another type.
+//
+// The payload is kept as encoded bytes rather than as a nested JSON object
+// because the signed bytes must survive re-encoding of the envelope.
+type Envelope struct {
+	Type       string
+	Payload    []byte
+	Signatures []*Signature
+}
+
+type Signature struct {
+	Fingerprint string
+	Signature   []byte
+}
+
+func New(typ string, payload any) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(stringx.Sanitize(data), data) {
+		return nil, errors.Errorf("payload contains invalid characters")
+	}
+
+	return &Envelope{
+		Type:    typ,
+		Payload: data,
+	}, nil
+}
+
+func Read(path string, typ string) (*Envelope, error) {
+	log.Tracef("%s: read signature", path)
+
+	data, err := iofs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(stringx.Sanitize(data), data) {
+		return nil, errors.Errorf("%s contains invalid characters", path)
+	}
+
+	env := &Envelope{}
+	err = json.Unmarshal(data, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Type != typ {
+		return nil, errors.Errorf("%s: incompatible type (%s vs %s)", path, env.Type, typ)
+	}
+
+	return env, nil
+}
+
+func (e *Envelope) Write(path string) error {
+	data, err := json.MarshalIndent(e, "", "    ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("%s: wrote %s", path, e.Type)
+	return nil
+}
+
+// Sign adds a signature by key.  It's an error to sign an envelope more
+// than once with the same key.
+func (e *Envelope) Sign(key cryptor.PrivateKey) error {
+	for _, sig := range e.Signatures {
+		if sig.Fingerprint == key.Fingerprint() {
+			return errors.Errorf("already signed by %s", key)
+		}
+	}
+
+	sig, err := key.Sign(e.message())
+	if err != nil {
+		return err
+	}
+
+	e.Signatures = append(e.Signatures, &Signature{
+		Fingerprint: key.Fingerprint(),
+		Signature:   sig,
+	})
+	return nil
+}
+
+// Verify returns the keys with a valid signature on the envelope.  An
+// error is returned if none of the keys have signed the envelope.
+func (e *Envelope) Verify(keys []cryptor.PublicKey) ([]cryptor.PublicKey, error) {
+	signers, err := e.Signers(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(signers) == 0 {
+		return nil, errors.Wrapf(cryptor.ErrInvalidSignature, "could not verify signature")
+	}
+
+	return signers, nil
+}
+
+// Signers is like Verify() but it's not an error if none of the keys have
+// signed the envelope.  Signatures by unknown keys are ignored.
+func (e *Envelope) Signers(keys []cryptor.PublicKey) ([]cryptor.PublicKey, error) {
+	signers := []cryptor.PublicKey{}
+
+	for _, sig := range e.Signatures {
+		if len(sig.Signature) != asymmetric.SignatureSize {
+			return nil, errors.Wrapf(cryptor.ErrInvalidSignature, "%s", sig.Fingerprint)
+		}
+
+		for _, key := range keys {
+			if key.Fingerprint() != sig.Fingerprint || contains(signers, key) {
+				continue
+			}
+
+			err := key.Verify(e.message(), sig.Signature)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s", key)
+			}
+			signers = append(signers, key)
+		}
+	}
+
+	return signers, nil
+}
+
+// Unmarshal decodes the payload.  It should only be invoked after the
+// envelope has been verified.
+func (e *Envelope) Unmarshal(payload any) error {
+	return json.Unmarshal(e.Payload, payload)
+}
+
+func (e *Envelope) message() []byte {
+	return append([]byte(e.Type+"\n"), e.Payload...)
+}
+
+func contains(keys []cryptor.PublicKey, key cryptor.PublicKey) bool {
+	for _, elt := range keys {
+		if elt.Fingerprint() == key.Fingerprint() {
+			return true
+		}
+	}
+	return false
+}